@@ -11,14 +11,16 @@ import (
 type (
 	// DoubleBuffer is a samples buffer for VST ProcessDouble function.
 	DoubleBuffer struct {
-		Frames int
-		data   []*C.double
+		Frames   int
+		capacity int
+		data     []*C.double
 	}
 
 	// FloatBuffer is a samples buffer for VST ProcessFloat function.
 	FloatBuffer struct {
-		Frames int
-		data   []*C.float
+		Frames   int
+		capacity int
+		data     []*C.float
 	}
 )
 
@@ -29,18 +31,64 @@ func NewDoubleBuffer(numChannels, frames int) DoubleBuffer {
 		b[i] = (*C.double)(C.calloc(1, C.size_t(C.sizeof_double*frames)))
 	}
 	return DoubleBuffer{
-		data:   b,
-		Frames: frames,
+		data:     b,
+		Frames:   frames,
+		capacity: frames,
 	}
 }
 
+// Resize sets number of frames in the buffer. Memory is reallocated only
+// if buffer capacity is exceeded, samples are zeroed in that case. Copies
+// of the buffer made before reallocation must not be used.
+func (b *DoubleBuffer) Resize(frames int) {
+	if frames > b.capacity {
+		for i := range b.data {
+			C.free(unsafe.Pointer(b.data[i]))
+			b.data[i] = (*C.double)(C.calloc(1, C.size_t(C.sizeof_double*frames)))
+		}
+		b.capacity = frames
+	}
+	b.Frames = frames
+}
+
+// Capacity returns maximum number of frames the buffer can hold without
+// reallocation.
+func (b DoubleBuffer) Capacity() int {
+	return b.capacity
+}
+
 // Read copies values to signal.Floating buffer. Buffers must have same
-// number of channels. Returns number of read frames.
+// number of channels. Returns number of read frames. Signals returned
+// by Floating are copied without per-sample calls, samples of sequential
+// signals are copied without index calls.
 func (b DoubleBuffer) Read(s signal.Floating) int {
 	mustSameChannels(s.Channels(), len(b.data))
 	// determine the size of data by picking up a lesser dimensions.
 	frames := min(s.Length(), b.Frames)
 
+	// buffer views are copied without per-sample calls.
+	switch v := s.(type) {
+	case doubleFloating:
+		for c := range b.data {
+			copy(v.b.Channel(c), b.Channel(c)[:frames])
+		}
+		return frames
+	case floatFloating:
+		v.b.CopyDouble(b)
+		return frames
+	}
+
+	// sequential signals are copied without index calls.
+	if sequential(s) {
+		for c := range b.data {
+			row := b.Channel(c)[:frames]
+			for i, idx := 0, c; i < len(row); i, idx = i+1, idx+len(b.data) {
+				s.SetSample(idx, row[i])
+			}
+		}
+		return frames
+	}
+
 	// copy data.
 	for c := 0; c < s.Channels(); c++ {
 		row := (*[1 << 30]C.double)(unsafe.Pointer(b.data[c]))
@@ -52,12 +100,37 @@ func (b DoubleBuffer) Read(s signal.Floating) int {
 }
 
 // Write copies values from signal.Floating. Buffers must have same
-// number of channels. Returns number of written frames.
+// number of channels. Returns number of written frames. Signals
+// returned by Floating are copied without per-sample calls, samples of
+// sequential signals are copied without index calls.
 func (b DoubleBuffer) Write(s signal.Floating) int {
 	mustSameChannels(s.Channels(), len(b.data))
 	// determine the size of data by picking up a lesser dimensions.
 	frames := min(s.Length(), b.Frames)
 
+	// buffer views are copied without per-sample calls.
+	switch v := s.(type) {
+	case doubleFloating:
+		for c := range b.data {
+			copy(b.Channel(c)[:frames], v.b.Channel(c))
+		}
+		return frames
+	case floatFloating:
+		b.CopyFloat(v.b)
+		return frames
+	}
+
+	// sequential signals are copied without index calls.
+	if sequential(s) {
+		for c := range b.data {
+			row := b.Channel(c)[:frames]
+			for i, idx := 0, c; i < len(row); i, idx = i+1, idx+len(b.data) {
+				row[i] = s.Sample(idx)
+			}
+		}
+		return frames
+	}
+
 	// copy data.
 	for c := 0; c < s.Channels(); c++ {
 		row := (*[1 << 30]C.double)(unsafe.Pointer(b.data[c]))
@@ -68,8 +141,17 @@ func (b DoubleBuffer) Write(s signal.Floating) int {
 	return frames
 }
 
-// cArray returns C array that is used as storage for buffer.
+// Channels returns number of channels in the buffer.
+func (b DoubleBuffer) Channels() int {
+	return len(b.data)
+}
+
+// cArray returns C array that is used as storage for buffer or nil if
+// buffer has no channels.
 func (b DoubleBuffer) cArray() **C.double {
+	if len(b.data) == 0 {
+		return nil
+	}
 	return (**C.double)(unsafe.Pointer(&b.data[0]))
 }
 
@@ -79,6 +161,41 @@ func (b DoubleBuffer) Channel(i int) []float64 {
 	return (*(*[1 << 30]float64)(unsafe.Pointer(b.data[i])))[:b.Frames:b.Frames]
 }
 
+// slice sets dst to refer frames [from, to) of the buffer. Memory of
+// dst is reused if possible.
+func (b DoubleBuffer) slice(dst *DoubleBuffer, from, to int) {
+	dst.data = dst.data[:0]
+	for i := range b.data {
+		dst.data = append(dst.data, (*C.double)(unsafe.Pointer(uintptr(unsafe.Pointer(b.data[i]))+uintptr(from)*C.sizeof_double)))
+	}
+	dst.Frames = to - from
+}
+
+// zero sets all samples to zero.
+func (b DoubleBuffer) zero() {
+	for i := range b.data {
+		c := b.Channel(i)
+		for j := range c {
+			c[j] = 0
+		}
+	}
+}
+
+// CopyFloat converts samples from float buffer. Buffers must have same
+// number of channels. Returns number of copied frames. It allows to
+// chain plugins that process signal with different precision.
+func (b DoubleBuffer) CopyFloat(src FloatBuffer) int {
+	mustSameChannels(len(src.data), len(b.data))
+	frames := min(src.Frames, b.Frames)
+	for c := range b.data {
+		dst, src := b.Channel(c)[:frames], src.Channel(c)[:frames]
+		for i := range dst {
+			dst[i] = float64(src[i])
+		}
+	}
+	return frames
+}
+
 // Free the allocated memory.
 func (b DoubleBuffer) Free() {
 	for i := range b.data {
@@ -93,18 +210,64 @@ func NewFloatBuffer(numChannels, frames int) FloatBuffer {
 		b[i] = (*C.float)(C.calloc(1, C.size_t(C.sizeof_float*frames)))
 	}
 	return FloatBuffer{
-		data:   b,
-		Frames: frames,
+		data:     b,
+		Frames:   frames,
+		capacity: frames,
+	}
+}
+
+// Resize sets number of frames in the buffer. Memory is reallocated only
+// if buffer capacity is exceeded, samples are zeroed in that case. Copies
+// of the buffer made before reallocation must not be used.
+func (b *FloatBuffer) Resize(frames int) {
+	if frames > b.capacity {
+		for i := range b.data {
+			C.free(unsafe.Pointer(b.data[i]))
+			b.data[i] = (*C.float)(C.calloc(1, C.size_t(C.sizeof_float*frames)))
+		}
+		b.capacity = frames
 	}
+	b.Frames = frames
+}
+
+// Capacity returns maximum number of frames the buffer can hold without
+// reallocation.
+func (b FloatBuffer) Capacity() int {
+	return b.capacity
 }
 
 // Read copies values to signal.Floating buffer. Buffers must have same
-// number of channels. Returns number of read frames.
+// number of channels. Returns number of read frames. Signals returned
+// by Floating are copied without per-sample calls, samples of sequential
+// signals are copied without index calls.
 func (b FloatBuffer) Read(s signal.Floating) int {
 	mustSameChannels(s.Channels(), len(b.data))
 	// determine the size of data by picking up a lesser dimensions.
 	frames := min(s.Length(), b.Frames)
 
+	// buffer views are copied without per-sample calls.
+	switch v := s.(type) {
+	case floatFloating:
+		for c := range b.data {
+			copy(v.b.Channel(c), b.Channel(c)[:frames])
+		}
+		return frames
+	case doubleFloating:
+		v.b.CopyFloat(b)
+		return frames
+	}
+
+	// sequential signals are copied without index calls.
+	if sequential(s) {
+		for c := range b.data {
+			row := b.Channel(c)[:frames]
+			for i, idx := 0, c; i < len(row); i, idx = i+1, idx+len(b.data) {
+				s.SetSample(idx, float64(row[i]))
+			}
+		}
+		return frames
+	}
+
 	// copy data.
 	for c := 0; c < s.Channels(); c++ {
 		row := (*[1 << 30]C.float)(unsafe.Pointer(b.data[c]))
@@ -116,12 +279,37 @@ func (b FloatBuffer) Read(s signal.Floating) int {
 }
 
 // Write copies values from signal.Floating. Buffers must have same
-// number of channels. Returns number of written frames.
+// number of channels. Returns number of written frames. Signals
+// returned by Floating are copied without per-sample calls, samples of
+// sequential signals are copied without index calls.
 func (b FloatBuffer) Write(s signal.Floating) int {
 	mustSameChannels(s.Channels(), len(b.data))
 	// determine the size of data by picking up a lesser dimensions.
 	frames := min(s.Length(), b.Frames)
 
+	// buffer views are copied without per-sample calls.
+	switch v := s.(type) {
+	case floatFloating:
+		for c := range b.data {
+			copy(b.Channel(c)[:frames], v.b.Channel(c))
+		}
+		return frames
+	case doubleFloating:
+		b.CopyDouble(v.b)
+		return frames
+	}
+
+	// sequential signals are copied without index calls.
+	if sequential(s) {
+		for c := range b.data {
+			row := b.Channel(c)[:frames]
+			for i, idx := 0, c; i < len(row); i, idx = i+1, idx+len(b.data) {
+				row[i] = float32(s.Sample(idx))
+			}
+		}
+		return frames
+	}
+
 	// copy data.
 	for c := 0; c < s.Channels(); c++ {
 		row := (*[1 << 30]C.float)(unsafe.Pointer(b.data[c]))
@@ -132,8 +320,17 @@ func (b FloatBuffer) Write(s signal.Floating) int {
 	return frames
 }
 
-// cArray returns C array that is used as storage for buffer.
+// Channels returns number of channels in the buffer.
+func (b FloatBuffer) Channels() int {
+	return len(b.data)
+}
+
+// cArray returns C array that is used as storage for buffer or nil if
+// buffer has no channels.
 func (b FloatBuffer) cArray() **C.float {
+	if len(b.data) == 0 {
+		return nil
+	}
 	return (**C.float)(unsafe.Pointer(&b.data[0]))
 }
 
@@ -143,6 +340,41 @@ func (b FloatBuffer) Channel(i int) []float32 {
 	return (*(*[1 << 30]float32)(unsafe.Pointer(b.data[i])))[:b.Frames:b.Frames]
 }
 
+// slice sets dst to refer frames [from, to) of the buffer. Memory of
+// dst is reused if possible.
+func (b FloatBuffer) slice(dst *FloatBuffer, from, to int) {
+	dst.data = dst.data[:0]
+	for i := range b.data {
+		dst.data = append(dst.data, (*C.float)(unsafe.Pointer(uintptr(unsafe.Pointer(b.data[i]))+uintptr(from)*C.sizeof_float)))
+	}
+	dst.Frames = to - from
+}
+
+// zero sets all samples to zero.
+func (b FloatBuffer) zero() {
+	for i := range b.data {
+		c := b.Channel(i)
+		for j := range c {
+			c[j] = 0
+		}
+	}
+}
+
+// CopyDouble converts samples from double buffer. Buffers must have same
+// number of channels. Returns number of copied frames. It allows to
+// chain plugins that process signal with different precision.
+func (b FloatBuffer) CopyDouble(src DoubleBuffer) int {
+	mustSameChannels(len(src.data), len(b.data))
+	frames := min(src.Frames, b.Frames)
+	for c := range b.data {
+		dst, src := b.Channel(c)[:frames], src.Channel(c)[:frames]
+		for i := range dst {
+			dst[i] = float32(src[i])
+		}
+	}
+	return frames
+}
+
 // Free the allocated memory.
 func (b FloatBuffer) Free() {
 	for _, c := range b.data {
@@ -164,6 +396,14 @@ func getFloatChannel(buf **C.float, i int) *C.float {
 	return *ptrPtr
 }
 
+// sequential returns true if samples of the signal are interleaved, so
+// sample of channel c in frame i has index i*channels+c. Signals that are
+// allocated by signal package are sequential.
+func sequential(s signal.Floating) bool {
+	channels := s.Channels()
+	return s.BufferIndex(0, 1) == channels && (channels < 2 || s.BufferIndex(1, 0) == 1)
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -55,6 +55,144 @@ func TestBuffer(t *testing.T) {
 	}
 	t.Run("mono iterate", testBuffer([][]float64{{1, 2, 3}}, iterate))
 	t.Run("stereo iterate", testBuffer([][]float64{{11, 12, 13}, {21, 22, 23}}, iterate))
+	view := func(f signal.Floating, b DoubleBuffer) {
+		signal.FloatingAsFloating(f, b.Floating())
+	}
+	t.Run("stereo view", testBuffer([][]float64{{11, 12, 13}, {21, 22, 23}}, view))
+	viewChannel := func(f signal.Floating, b DoubleBuffer) {
+		v := b.Floating()
+		for c := 0; c < f.Channels(); c++ {
+			for i := 0; i < b.Frames; i++ {
+				v.Channel(c).Slice(i, i+1).SetSample(0, f.Sample(f.BufferIndex(c, i)))
+			}
+		}
+	}
+	t.Run("stereo channel view", testBuffer([][]float64{{11, 12, 13}, {21, 22, 23}}, viewChannel))
+	generic := func(f signal.Floating, b DoubleBuffer) {
+		b.Write(genericFloating{f})
+	}
+	t.Run("stereo generic write", testBuffer([][]float64{{11, 12, 13}, {21, 22, 23}}, generic))
+}
+
+func TestBufferReadWrite(t *testing.T) {
+	floats := [][]float64{{11, 12, 13}, {21, 22, 23}}
+	alloc := signal.Allocator{
+		Channels: len(floats),
+		Length:   len(floats[0]),
+		Capacity: len(floats[0]),
+	}
+	testReadWrite := func(allocFn func() signal.Floating) func(*testing.T) {
+		return func(t *testing.T) {
+			src := allocFn()
+			signal.WriteStripedFloat64(floats, src)
+
+			db := NewDoubleBuffer(alloc.Channels, alloc.Length)
+			defer db.Free()
+			assertEqual(t, "double written", db.Write(src), alloc.Length)
+			dst := allocFn()
+			assertEqual(t, "double read", db.Read(dst), alloc.Length)
+			assertEqual(t, "double samples", readStriped(dst), floats)
+
+			fb := NewFloatBuffer(alloc.Channels, alloc.Length)
+			defer fb.Free()
+			assertEqual(t, "float written", fb.Write(src), alloc.Length)
+			dst = allocFn()
+			assertEqual(t, "float read", fb.Read(dst), alloc.Length)
+			assertEqual(t, "float samples", readStriped(dst), floats)
+		}
+	}
+	t.Run("float64", testReadWrite(alloc.Float64))
+	t.Run("float32", testReadWrite(alloc.Float32))
+	t.Run("generic view", testReadWrite(func() signal.Floating {
+		return genericFloating{NewDoubleBuffer(alloc.Channels, alloc.Length).Floating()}
+	}))
+	t.Run("planar", testReadWrite(func() signal.Floating {
+		return planarFloating{alloc.Float64()}
+	}))
+	t.Run("double view", testReadWrite(func() signal.Floating {
+		return NewDoubleBuffer(alloc.Channels, alloc.Length).Floating()
+	}))
+	t.Run("float view", testReadWrite(func() signal.Floating {
+		return NewFloatBuffer(alloc.Channels, alloc.Length).Floating()
+	}))
+}
+
+func BenchmarkBuffer(b *testing.B) {
+	const (
+		channels = 2
+		frames   = 1024
+	)
+	alloc := signal.Allocator{
+		Channels: channels,
+		Length:   frames,
+		Capacity: frames,
+	}
+	double := NewDoubleBuffer(channels, frames)
+	defer double.Free()
+	float := NewFloatBuffer(channels, frames)
+	defer float.Free()
+	view := NewDoubleBuffer(channels, frames)
+	defer view.Free()
+	buffers := []struct {
+		name        string
+		read, write func(signal.Floating) int
+	}{
+		{"double", double.Read, double.Write},
+		{"float", float.Read, float.Write},
+	}
+	signals := []struct {
+		name string
+		signal.Floating
+	}{
+		{"float64", alloc.Float64()},
+		{"float32", alloc.Float32()},
+		{"view", view.Floating()},
+	}
+	benchmark := func(s signal.Floating, fn func(signal.Floating) int) func(*testing.B) {
+		return func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				fn(s)
+			}
+		}
+	}
+	for _, buf := range buffers {
+		for _, s := range signals {
+			b.Run(buf.name+" write "+s.name, benchmark(s.Floating, buf.write))
+			b.Run(buf.name+" read "+s.name, benchmark(s.Floating, buf.read))
+		}
+	}
+}
+
+// genericFloating hides type of buffer view to disable its fast path.
+type genericFloating struct {
+	signal.Floating
+}
+
+// planarFloating stores channels one after another, so its samples are
+// not sequential.
+type planarFloating struct {
+	signal.Floating
+}
+
+func (s planarFloating) BufferIndex(channel, idx int) int {
+	return channel*s.Length() + idx
+}
+
+func (s planarFloating) Sample(i int) float64 {
+	return s.Floating.Sample(s.Floating.BufferIndex(i/s.Length(), i%s.Length()))
+}
+
+func (s planarFloating) SetSample(i int, value float64) {
+	s.Floating.SetSample(s.Floating.BufferIndex(i/s.Length(), i%s.Length()), value)
+}
+
+func readStriped(s signal.Floating) [][]float64 {
+	floats := make([][]float64, s.Channels())
+	for i := range floats {
+		floats[i] = make([]float64, s.Length())
+	}
+	signal.ReadStripedFloat64(s, floats)
+	return floats
 }
 
 func assertEqual(t *testing.T, name string, result, expected interface{}) {
@@ -63,3 +201,69 @@ func assertEqual(t *testing.T, name string, result, expected interface{}) {
 		t.Fatalf("%v\nresult: \t%T\t%+v \nexpected: \t%T\t%+v", name, result, result, expected, expected)
 	}
 }
+
+func TestBufferResize(t *testing.T) {
+	b := NewDoubleBuffer(2, 4)
+	defer b.Free()
+	data := b.data[0]
+	b.Resize(2)
+	assertEqual(t, "shrink frames", b.Frames, 2)
+	assertEqual(t, "shrink capacity", b.Capacity(), 4)
+	assertEqual(t, "shrink memory", b.data[0], data)
+	b.Resize(8)
+	assertEqual(t, "grow frames", b.Frames, 8)
+	assertEqual(t, "grow capacity", b.Capacity(), 8)
+	assertEqual(t, "grow samples", b.Channel(1), make([]float64, 8))
+
+	f := NewFloatBuffer(1, 4)
+	defer f.Free()
+	f.Resize(16)
+	assertEqual(t, "float frames", f.Frames, 16)
+	assertEqual(t, "float capacity", f.Capacity(), 16)
+}
+
+func TestBufferPool(t *testing.T) {
+	var p BufferPool
+	defer p.Free()
+	d := p.GetDouble(2, 8)
+	data := d.data[0]
+	p.PutDouble(d)
+	d = p.GetDouble(2, 4)
+	assertEqual(t, "reused double", d.data[0], data)
+	assertEqual(t, "double frames", d.Frames, 4)
+	other := p.GetDouble(1, 4)
+	assertEqual(t, "new double channels", other.Channels(), 1)
+	p.PutDouble(d)
+	p.PutDouble(other)
+
+	f := p.GetFloat(2, 4)
+	p.PutFloat(f)
+	f = p.GetFloat(2, 16)
+	assertEqual(t, "float frames", f.Frames, 16)
+	assertEqual(t, "float capacity", f.Capacity(), 16)
+	p.PutFloat(f)
+
+	var nilPool *BufferPool
+	d = nilPool.GetDouble(1, 2)
+	assertEqual(t, "nil pool frames", d.Frames, 2)
+	nilPool.PutDouble(d)
+	nilPool.Free()
+}
+
+func TestBufferConvert(t *testing.T) {
+	d := NewDoubleBuffer(2, 3)
+	defer d.Free()
+	f := NewFloatBuffer(2, 2)
+	defer f.Free()
+	copy(d.Channel(0), []float64{0.5, -0.25, 1})
+	copy(d.Channel(1), []float64{1, 0.75, -1})
+	assertEqual(t, "double to float frames", f.CopyDouble(d), 2)
+	assertEqual(t, "float channel 0", f.Channel(0), []float32{0.5, -0.25})
+	assertEqual(t, "float channel 1", f.Channel(1), []float32{1, 0.75})
+
+	back := NewDoubleBuffer(2, 2)
+	defer back.Free()
+	assertEqual(t, "float to double frames", back.CopyFloat(f), 2)
+	assertEqual(t, "double channel 0", back.Channel(0), []float64{0.5, -0.25})
+	assertEqual(t, "double channel 1", back.Channel(1), []float64{1, 0.75})
+}
@@ -0,0 +1,426 @@
+// +build !plugin
+
+package vst2
+
+import (
+	"context"
+	"sync"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+type (
+	// Chain is pipe component that processes signal with plugins back to
+	// back. Signal stays in C buffers between plugins. Bypassed plugins
+	// delay signal by their latency, so bypass doesn't shift the timing.
+	// Cumulative latency of plugins is compensated: the first frames
+	// that precede delayed signal are dropped, so output blocks are
+	// shorter until latency is consumed. Dropped frames are flushed as
+	// a tail when the last block, that is shorter than buffer size, is
+	// processed. If input ends with a full block, the tail is lost. Bypass
+	// and order of plugins can be changed during processing, changes are
+	// applied before the next block.
+	Chain struct {
+		mu sync.Mutex
+		// slots that will be applied before the next block.
+		slots   []*chainSlot
+		changed bool
+		// slots used by processing routine.
+		active []*chainSlot
+		// skip is number of frames that must be dropped to compensate
+		// latency, pending is number of dropped frames that aren't
+		// flushed yet.
+		skip, pending int
+		// BufferPool is optional, if set then processing buffers are
+		// taken from it and released into it when chain is flushed.
+		BufferPool *BufferPool
+	}
+
+	chainSlot struct {
+		plugin *Plugin
+		bypass bool
+		delay  *delayLine
+	}
+
+	// delayLine delays signal by fixed number of frames.
+	delayLine struct {
+		samples [][]float64
+		pos     int
+	}
+)
+
+// NewChain returns chain of provided plugins.
+func NewChain(plugins ...*Plugin) *Chain {
+	slots := make([]*chainSlot, 0, len(plugins))
+	for _, p := range plugins {
+		slots = append(slots, &chainSlot{plugin: p})
+	}
+	return &Chain{
+		slots:   slots,
+		changed: true,
+	}
+}
+
+// Plugins returns plugins in the order they will process the next block.
+func (c *Chain) Plugins() []*Plugin {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	plugins := make([]*Plugin, 0, len(c.slots))
+	for _, s := range c.slots {
+		plugins = append(plugins, s.plugin)
+	}
+	return plugins
+}
+
+// SetBypass enables or disables bypass of the plugin in provided slot.
+func (c *Chain) SetBypass(slot int, bypass bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := *c.slots[slot]
+	s.bypass = bypass
+	c.slots[slot] = &s
+	c.changed = true
+}
+
+// Bypassed returns true if plugin in provided slot is bypassed.
+func (c *Chain) Bypassed(slot int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.slots[slot].bypass
+}
+
+// Move moves plugin from one slot to another, plugins between slots are
+// shifted.
+func (c *Chain) Move(from, to int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	slots := make([]*chainSlot, 0, len(c.slots))
+	slots = append(slots, c.slots[:from]...)
+	slots = append(slots, c.slots[from+1:]...)
+	slots = append(slots[:to], append([]*chainSlot{c.slots[from]}, slots[to:]...)...)
+	c.slots = slots
+	c.changed = true
+}
+
+// Latency returns cumulative latency of the chain in frames. Bypassed
+// plugins are included, because their signal is delayed.
+func (c *Chain) Latency() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var latency int
+	for _, s := range c.slots {
+		latency += s.plugin.InitialDelay()
+	}
+	return latency
+}
+
+// apply makes pending changes active.
+func (c *Chain) apply() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.changed {
+		c.active = append(c.active[:0], c.slots...)
+		c.changed = false
+	}
+}
+
+// Allocator returns pipe processor allocator that can be plugged into
+// line. Init function is applied to every plugin.
+func (c *Chain) Allocator(init ProcessorInitFunc) pipe.ProcessorAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Processor, error) {
+		precision := ProcessDouble
+		channels := props.Channels
+		for _, p := range c.Plugins() {
+			if !p.CanProcessFloat64() {
+				precision = ProcessFloat
+			}
+			channels = maxInt(channels, maxInt(p.NumInputs(), p.NumOutputs()))
+		}
+		for _, p := range c.Plugins() {
+			p.Start()
+			p.SetSampleRate(props.SampleRate)
+			p.SetBufferSize(bufferSize)
+			p.SetProcessPrecision(precision)
+			if init != nil {
+				init(p)
+			}
+		}
+		var (
+			processFn pipe.ProcessFunc
+			flushFn   pipe.FlushFunc
+		)
+		if precision == ProcessDouble {
+			processFn, flushFn = c.doubleFns(props.Channels, channels, bufferSize)
+		} else {
+			processFn, flushFn = c.floatFns(props.Channels, channels, bufferSize)
+		}
+		return pipe.Processor{
+			SignalProperties: props,
+			StartFunc: func(context.Context) error {
+				c.mu.Lock()
+				defer c.mu.Unlock()
+				// latency is known after plugins are resumed.
+				var latency int
+				for i, s := range c.slots {
+					s.plugin.Resume()
+					c.slots[i] = &chainSlot{
+						plugin: s.plugin,
+						bypass: s.bypass,
+						delay:  newDelayLine(channels, s.plugin.InitialDelay()),
+					}
+					latency += s.plugin.InitialDelay()
+				}
+				c.changed = true
+				c.skip, c.pending = latency, 0
+				return nil
+			},
+			ProcessFunc: processFn,
+			FlushFunc:   flushFn,
+		}, nil
+	}
+}
+
+func (c *Chain) doubleFns(pipeChannels, channels, bufferSize int) (pipe.ProcessFunc, pipe.FlushFunc) {
+	pool := c.BufferPool
+	a := pool.GetDouble(channels, bufferSize)
+	b := pool.GetDouble(channels, bufferSize)
+	// process passes frames through active slots and returns processed
+	// signal. Silence is processed if input is nil.
+	process := func(in signal.Floating, frames int) DoubleBuffer {
+		src, dst := a, b
+		current := pipeChannels
+		if in != nil {
+			doubleView(src, current, frames).Write(in)
+		} else {
+			doubleView(src, current, frames).zero()
+		}
+		for _, s := range c.active {
+			if s.bypass {
+				s.delay.double(doubleView(src, current, frames), true)
+				continue
+			}
+			s.delay.double(doubleView(src, current, frames), false)
+			numIn, numOut := s.plugin.NumInputs(), s.plugin.NumOutputs()
+			doubleView(src, numIn, frames).zeroFrom(current)
+			s.plugin.ProcessDouble(doubleView(src, numIn, frames), doubleView(dst, numOut, frames))
+			src, dst = dst, src
+			current = numOut
+		}
+		output := doubleView(src, pipeChannels, frames)
+		output.zeroFrom(current)
+		return output
+	}
+	var view DoubleBuffer
+	return func(in, out signal.Floating) (int, error) {
+			c.apply()
+			frames := in.Length()
+			output := process(in, frames)
+			drop := c.drop(frames)
+			output.slice(&view, drop, frames)
+			n := view.Read(out)
+			if skip, tail := c.tail(frames, n, out.Length()); tail > 0 {
+				// latency that isn't consumed by input is consumed by
+				// silence.
+				for ; skip > 0; skip -= bufferSize {
+					process(nil, min(skip, bufferSize))
+				}
+				n += process(nil, tail).Read(out.Slice(n, n+tail))
+			}
+			return n, nil
+		},
+		func(context.Context) error {
+			for _, p := range c.Plugins() {
+				p.Suspend()
+			}
+			pool.PutDouble(a)
+			pool.PutDouble(b)
+			return nil
+		}
+}
+
+func (c *Chain) floatFns(pipeChannels, channels, bufferSize int) (pipe.ProcessFunc, pipe.FlushFunc) {
+	pool := c.BufferPool
+	a := pool.GetFloat(channels, bufferSize)
+	b := pool.GetFloat(channels, bufferSize)
+	// process passes frames through active slots and returns processed
+	// signal. Silence is processed if input is nil.
+	process := func(in signal.Floating, frames int) FloatBuffer {
+		src, dst := a, b
+		current := pipeChannels
+		if in != nil {
+			floatView(src, current, frames).Write(in)
+		} else {
+			floatView(src, current, frames).zero()
+		}
+		for _, s := range c.active {
+			if s.bypass {
+				s.delay.float(floatView(src, current, frames), true)
+				continue
+			}
+			s.delay.float(floatView(src, current, frames), false)
+			numIn, numOut := s.plugin.NumInputs(), s.plugin.NumOutputs()
+			floatView(src, numIn, frames).zeroFrom(current)
+			s.plugin.ProcessFloat(floatView(src, numIn, frames), floatView(dst, numOut, frames))
+			src, dst = dst, src
+			current = numOut
+		}
+		output := floatView(src, pipeChannels, frames)
+		output.zeroFrom(current)
+		return output
+	}
+	var view FloatBuffer
+	return func(in, out signal.Floating) (int, error) {
+			c.apply()
+			frames := in.Length()
+			output := process(in, frames)
+			drop := c.drop(frames)
+			output.slice(&view, drop, frames)
+			n := view.Read(out)
+			if skip, tail := c.tail(frames, n, out.Length()); tail > 0 {
+				// latency that isn't consumed by input is consumed by
+				// silence.
+				for ; skip > 0; skip -= bufferSize {
+					process(nil, min(skip, bufferSize))
+				}
+				n += process(nil, tail).Read(out.Slice(n, n+tail))
+			}
+			return n, nil
+		},
+		func(context.Context) error {
+			for _, p := range c.Plugins() {
+				p.Suspend()
+			}
+			pool.PutFloat(a)
+			pool.PutFloat(b)
+			return nil
+		}
+}
+
+// drop returns number of frames in the beginning of processed block that
+// must be dropped to compensate latency.
+func (c *Chain) drop(frames int) int {
+	drop := min(c.skip, frames)
+	c.skip -= drop
+	c.pending += drop
+	return drop
+}
+
+// tail returns number of dropped frames that must be flushed after
+// processed block. Tail is flushed after the last block, that is shorter
+// than buffer size, and is limited by free space in the output. If input
+// was shorter than latency, number of frames that must be skipped before
+// the tail is returned as well.
+func (c *Chain) tail(frames, written, capacity int) (skip, tail int) {
+	if frames == capacity {
+		return 0, 0
+	}
+	skip, tail = c.skip, min(c.pending, capacity-written)
+	c.skip, c.pending = 0, c.pending-tail
+	return skip, tail
+}
+
+// doubleView returns buffer that refers first channels and frames of
+// provided buffer.
+func doubleView(b DoubleBuffer, channels, frames int) DoubleBuffer {
+	return DoubleBuffer{
+		Frames: frames,
+		data:   b.data[:channels:channels],
+	}
+}
+
+// floatView returns buffer that refers first channels and frames of
+// provided buffer.
+func floatView(b FloatBuffer, channels, frames int) FloatBuffer {
+	return FloatBuffer{
+		Frames: frames,
+		data:   b.data[:channels:channels],
+	}
+}
+
+// zeroFrom sets samples of channels starting from provided one to zero.
+func (b DoubleBuffer) zeroFrom(channel int) {
+	for c := channel; c < len(b.data); c++ {
+		s := b.Channel(c)
+		for i := range s {
+			s[i] = 0
+		}
+	}
+}
+
+// zeroFrom sets samples of channels starting from provided one to zero.
+func (b FloatBuffer) zeroFrom(channel int) {
+	for c := channel; c < len(b.data); c++ {
+		s := b.Channel(c)
+		for i := range s {
+			s[i] = 0
+		}
+	}
+}
+
+// newDelayLine returns delay line for provided number of channels or nil
+// if delay is zero.
+func newDelayLine(channels, frames int) *delayLine {
+	if frames <= 0 {
+		return nil
+	}
+	samples := make([][]float64, channels)
+	for i := range samples {
+		samples[i] = make([]float64, frames)
+	}
+	return &delayLine{samples: samples}
+}
+
+// double pushes signal into delay line. If replace is true, signal is
+// replaced with delayed one.
+func (d *delayLine) double(b DoubleBuffer, replace bool) {
+	if d == nil {
+		return
+	}
+	size := len(d.samples[0])
+	for c := range b.data {
+		s, line, pos := b.Channel(c), d.samples[c], d.pos
+		for i := range s {
+			v := line[pos]
+			line[pos] = s[i]
+			if replace {
+				s[i] = v
+			}
+			if pos++; pos == size {
+				pos = 0
+			}
+		}
+	}
+	d.pos = (d.pos + b.Frames) % size
+}
+
+// float pushes signal into delay line. If replace is true, signal is
+// replaced with delayed one.
+func (d *delayLine) float(b FloatBuffer, replace bool) {
+	if d == nil {
+		return
+	}
+	size := len(d.samples[0])
+	for c := range b.data {
+		s, line, pos := b.Channel(c), d.samples[c], d.pos
+		for i := range s {
+			v := line[pos]
+			line[pos] = float64(s[i])
+			if replace {
+				s[i] = float32(v)
+			}
+			if pos++; pos == size {
+				pos = 0
+			}
+		}
+	}
+	d.pos = (d.pos + b.Frames) % size
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,47 @@
+// +build !plugin
+
+package vst2
+
+import "testing"
+
+func TestChainSlots(t *testing.T) {
+	p1, p2, p3 := &Plugin{}, &Plugin{}, &Plugin{}
+	c := NewChain(p1, p2, p3)
+	c.Move(0, 2)
+	assertEqual(t, "moved forward", c.Plugins(), []*Plugin{p2, p3, p1})
+	c.Move(2, 0)
+	assertEqual(t, "moved back", c.Plugins(), []*Plugin{p1, p2, p3})
+
+	c.apply()
+	active := c.active[1]
+	c.SetBypass(1, true)
+	assertEqual(t, "bypassed", c.Bypassed(1), true)
+	assertEqual(t, "active before apply", active.bypass, false)
+	c.apply()
+	assertEqual(t, "active after apply", c.active[1].bypass, true)
+}
+
+func TestDelayLine(t *testing.T) {
+	d := newDelayLine(2, 2)
+	b := NewDoubleBuffer(2, 3)
+	defer b.Free()
+	copy(b.Channel(0), []float64{1, 2, 3})
+	copy(b.Channel(1), []float64{4, 5, 6})
+	d.double(b, true)
+	assertEqual(t, "channel 0", b.Channel(0), []float64{0, 0, 1})
+	assertEqual(t, "channel 1", b.Channel(1), []float64{0, 0, 4})
+
+	f := NewFloatBuffer(2, 2)
+	defer f.Free()
+	copy(f.Channel(0), []float32{7, 8})
+	copy(f.Channel(1), []float32{9, 10})
+	d.float(f, false)
+	assertEqual(t, "fed channel 0", f.Channel(0), []float32{7, 8})
+	copy(f.Channel(0), []float32{0, 0})
+	d.float(f, true)
+	assertEqual(t, "delayed channel 0", f.Channel(0), []float32{7, 8})
+
+	var empty *delayLine
+	empty.double(b, true)
+	assertEqual(t, "nil delay", b.Channel(0), []float64{0, 0, 1})
+}
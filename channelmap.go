@@ -0,0 +1,158 @@
+package vst2
+
+import "fmt"
+
+// ChannelMap routes signal between buffers with different number of
+// channels. Every row defines single output channel as a weighted sum of
+// input channels, so number of rows is number of output channels and
+// length of every row is number of input channels. Output channels with
+// zero weights are silent.
+type ChannelMap [][]float64
+
+// IdentityMap maps input channels to output channels with the same
+// index. Extra output channels are silent and extra input channels are
+// dropped.
+func IdentityMap(in, out int) ChannelMap {
+	m := newChannelMap(in, out)
+	for i := 0; i < in && i < out; i++ {
+		m[i][i] = 1
+	}
+	return m
+}
+
+// DuplicateMap repeats input channels to fill all output channels, e.g.
+// mono input is duplicated into every output channel.
+func DuplicateMap(in, out int) ChannelMap {
+	m := newChannelMap(in, out)
+	if in == 0 {
+		return m
+	}
+	for i := range m {
+		m[i][i%in] = 1
+	}
+	return m
+}
+
+// DownmixMap averages input channels into output channels. Input channel
+// i is mixed into output channel i % out, e.g. stereo input is mixed into
+// mono output with equal weights.
+func DownmixMap(in, out int) ChannelMap {
+	m := newChannelMap(in, out)
+	if out == 0 {
+		return m
+	}
+	for i := 0; i < in; i++ {
+		m[i%out][i] = 1
+	}
+	for _, row := range m {
+		var n float64
+		for _, w := range row {
+			n += w
+		}
+		for j := range row {
+			if n > 0 {
+				row[j] /= n
+			}
+		}
+	}
+	return m
+}
+
+// SelectMap selects provided input channels as output channels, e.g.
+// output pair of multi-output instrument. Negative channel is silent.
+// Returns error if selected channel exceeds number of inputs.
+func SelectMap(in int, channels ...int) (ChannelMap, error) {
+	m := make(ChannelMap, len(channels))
+	for i, c := range channels {
+		if c >= in {
+			return nil, fmt.Errorf("selected channel %d exceeds %d inputs", c, in)
+		}
+		m[i] = make([]float64, in)
+		if c >= 0 {
+			m[i][c] = 1
+		}
+	}
+	return m, nil
+}
+
+func newChannelMap(in, out int) ChannelMap {
+	m := make(ChannelMap, out)
+	for i := range m {
+		m[i] = make([]float64, in)
+	}
+	return m
+}
+
+// Validate returns error if map cannot route provided number of input
+// channels into provided number of output channels.
+func (m ChannelMap) Validate(in, out int) error {
+	if len(m) != out {
+		return fmt.Errorf("channel map has %d outputs, expected %d", len(m), out)
+	}
+	for i := range m {
+		if len(m[i]) != in {
+			return fmt.Errorf("channel map output %d has %d inputs, expected %d", i, len(m[i]), in)
+		}
+	}
+	return nil
+}
+
+// isIdentity returns true if map routes every channel into channel with
+// the same index without changes.
+func (m ChannelMap) isIdentity() bool {
+	for i := range m {
+		if len(m[i]) != len(m) {
+			return false
+		}
+		for j, w := range m[i] {
+			if (i == j && w != 1) || (i != j && w != 0) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Double routes samples from in to out buffer. Map must be valid for
+// provided buffers. Returns number of routed frames.
+func (m ChannelMap) Double(in, out DoubleBuffer) int {
+	frames := min(in.Frames, out.Frames)
+	for i := range m {
+		dst := out.Channel(i)[:frames]
+		for k := range dst {
+			dst[k] = 0
+		}
+		for j, w := range m[i] {
+			if w == 0 {
+				continue
+			}
+			src := in.Channel(j)[:frames]
+			for k := range dst {
+				dst[k] += w * src[k]
+			}
+		}
+	}
+	return frames
+}
+
+// Float routes samples from in to out buffer. Map must be valid for
+// provided buffers. Returns number of routed frames.
+func (m ChannelMap) Float(in, out FloatBuffer) int {
+	frames := min(in.Frames, out.Frames)
+	for i := range m {
+		dst := out.Channel(i)[:frames]
+		for k := range dst {
+			dst[k] = 0
+		}
+		for j, w := range m[i] {
+			if w == 0 {
+				continue
+			}
+			src := in.Channel(j)[:frames]
+			for k := range dst {
+				dst[k] += float32(w) * src[k]
+			}
+		}
+	}
+	return frames
+}
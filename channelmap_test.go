@@ -0,0 +1,55 @@
+package vst2_test
+
+import (
+	"testing"
+
+	"pipelined.dev/audio/vst2"
+)
+
+func TestChannelMap(t *testing.T) {
+	testMap := func(m vst2.ChannelMap, in [][]float64, expected [][]float64) func(*testing.T) {
+		return func(t *testing.T) {
+			assertEqual(t, "valid", m.Validate(len(in), len(expected)), nil)
+			frames := len(in[0])
+			din := vst2.NewDoubleBuffer(len(in), frames)
+			defer din.Free()
+			for c := range in {
+				copy(din.Channel(c), in[c])
+			}
+			dout := vst2.NewDoubleBuffer(len(expected), frames)
+			defer dout.Free()
+			assertEqual(t, "double frames", m.Double(din, dout), frames)
+			for c := range expected {
+				assertEqual(t, "double channel", dout.Channel(c), expected[c])
+			}
+
+			fin := vst2.NewFloatBuffer(len(in), frames)
+			defer fin.Free()
+			fin.CopyDouble(din)
+			fout := vst2.NewFloatBuffer(len(expected), frames)
+			defer fout.Free()
+			assertEqual(t, "float frames", m.Float(fin, fout), frames)
+			for c := range expected {
+				for i := range expected[c] {
+					assertEqual(t, "float sample", fout.Channel(c)[i], float32(expected[c][i]))
+				}
+			}
+		}
+	}
+	t.Run("identity", testMap(vst2.IdentityMap(2, 2), [][]float64{{1, 2}, {3, 4}}, [][]float64{{1, 2}, {3, 4}}))
+	t.Run("padding", testMap(vst2.IdentityMap(2, 3), [][]float64{{1, 2}, {3, 4}}, [][]float64{{1, 2}, {3, 4}, {0, 0}}))
+	t.Run("mono to stereo", testMap(vst2.DuplicateMap(1, 2), [][]float64{{1, 2}}, [][]float64{{1, 2}, {1, 2}}))
+	t.Run("stereo to mono", testMap(vst2.DownmixMap(2, 1), [][]float64{{1, 2}, {3, 4}}, [][]float64{{2, 3}}))
+	pair, err := vst2.SelectMap(4, 2, 3)
+	assertEqual(t, "select error", err, nil)
+	t.Run("select pair", testMap(pair, [][]float64{{1, 1}, {2, 2}, {3, 3}, {4, 4}}, [][]float64{{3, 3}, {4, 4}}))
+	silent, err := vst2.SelectMap(2, -1, 1)
+	assertEqual(t, "select silent error", err, nil)
+	t.Run("select silent", testMap(silent, [][]float64{{1, 2}, {3, 4}}, [][]float64{{0, 0}, {3, 4}}))
+	t.Run("invalid", func(t *testing.T) {
+		assertNotNil(t, "outputs", vst2.IdentityMap(2, 2).Validate(2, 3))
+		assertNotNil(t, "inputs", vst2.IdentityMap(2, 2).Validate(3, 2))
+		_, err := vst2.SelectMap(2, 0, 2)
+		assertNotNil(t, "select", err)
+	})
+}
@@ -0,0 +1,121 @@
+package vst2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// chunkMagic identifies chunks produced by default serializer.
+	chunkMagic int32 = 'G'<<24 | 'o'<<16 | 'C'<<8 | 'k'<<0
+	// chunkFormat is a version of default serializer format.
+	chunkFormat int32 = 1
+)
+
+// ErrInvalidChunk is returned when chunk data wasn't produced by default
+// chunk serializer.
+var ErrInvalidChunk = errors.New("invalid chunk")
+
+// Chunk is a plugin state that is saved and loaded by default chunk
+// serializer. It's used by Go plugins that don't define both GetChunkFunc
+// and SetChunkFunc.
+type Chunk struct {
+	// Version of plugin that saved the chunk.
+	Version int32
+	// Index of current program.
+	Program int
+	// Values of plugin parameters.
+	Values []float32
+	// Programs are only saved in bank chunks.
+	Programs []Program
+	// State is additional plugin state that is not stored in parameters.
+	State []byte
+}
+
+// MarshalBinary encodes chunk into binary form.
+func (c Chunk) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+	w := func(v interface{}) {
+		// writes to bytes.Buffer never fail.
+		_ = binary.Write(&b, binary.LittleEndian, v)
+	}
+	w(chunkMagic)
+	w(chunkFormat)
+	w(c.Version)
+	w(int32(c.Program))
+	w(int32(len(c.Values)))
+	w(c.Values)
+	w(int32(len(c.Programs)))
+	for i := range c.Programs {
+		w(int32(len(c.Programs[i].Name)))
+		b.WriteString(c.Programs[i].Name)
+		w(int32(len(c.Programs[i].Values)))
+		w(c.Programs[i].Values)
+	}
+	w(int32(len(c.State)))
+	b.Write(c.State)
+	return b.Bytes(), nil
+}
+
+// UnmarshalBinary decodes chunk from binary form.
+func (c *Chunk) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	var (
+		magic, format, n int32
+		err              error
+	)
+	read := func(v interface{}) {
+		if err == nil {
+			err = binary.Read(r, binary.LittleEndian, v)
+		}
+	}
+	readLen := func() int {
+		read(&n)
+		if err == nil && (n < 0 || int(n) > r.Len()) {
+			err = ErrInvalidChunk
+		}
+		if err != nil {
+			return 0
+		}
+		return int(n)
+	}
+	readBytes := func() []byte {
+		b := make([]byte, readLen())
+		if err == nil {
+			_, err = io.ReadFull(r, b)
+		}
+		return b
+	}
+	readValues := func() []float32 {
+		v := make([]float32, readLen())
+		read(v)
+		return v
+	}
+
+	read(&magic)
+	if err != nil || magic != chunkMagic {
+		return ErrInvalidChunk
+	}
+	read(&format)
+	if err == nil && format != chunkFormat {
+		return fmt.Errorf("unsupported chunk format: %d", format)
+	}
+	var program int32
+	read(&c.Version)
+	read(&program)
+	c.Program = int(program)
+	c.Values = readValues()
+	c.Programs = make([]Program, readLen())
+	for i := range c.Programs {
+		c.Programs[i].Name = string(readBytes())
+		c.Programs[i].Values = readValues()
+	}
+	c.State = readBytes()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidChunk, err)
+	}
+	return nil
+}
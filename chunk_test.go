@@ -0,0 +1,46 @@
+package vst2_test
+
+import (
+	"errors"
+	"testing"
+
+	"pipelined.dev/audio/vst2"
+)
+
+func TestChunk(t *testing.T) {
+	testChunk := func(c vst2.Chunk) func(*testing.T) {
+		return func(t *testing.T) {
+			data, err := c.MarshalBinary()
+			assertEqual(t, "marshal error", err, nil)
+
+			var result vst2.Chunk
+			err = result.UnmarshalBinary(data)
+			assertEqual(t, "unmarshal error", err, nil)
+			assertEqual(t, "chunk", result, c)
+		}
+	}
+	t.Run("preset", testChunk(vst2.Chunk{
+		Version:  1000,
+		Values:   []float32{0.1, 0.5},
+		Programs: []vst2.Program{},
+		State:    []byte{},
+	}))
+	t.Run("bank", testChunk(vst2.Chunk{
+		Version: 1001,
+		Program: 1,
+		Values:  []float32{0.1, 0.5},
+		Programs: []vst2.Program{
+			{Name: "first", Values: []float32{0.1, 0.5}},
+			{Name: "second", Values: []float32{1, 0}},
+		},
+		State: []byte("state"),
+	}))
+	t.Run("invalid", func(t *testing.T) {
+		var c vst2.Chunk
+		err := c.UnmarshalBinary([]byte("this is not a chunk"))
+		assertEqual(t, "invalid chunk", errors.Is(err, vst2.ErrInvalidChunk), true)
+		data, _ := vst2.Chunk{Values: []float32{1}}.MarshalBinary()
+		err = c.UnmarshalBinary(data[:len(data)-2])
+		assertEqual(t, "truncated chunk", errors.Is(err, vst2.ErrInvalidChunk), true)
+	})
+}
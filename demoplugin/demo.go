@@ -15,9 +15,9 @@ func init() {
 	)
 	vst2.PluginAllocator = func(h vst2.Host) (vst2.Plugin, vst2.Dispatcher) {
 		gain := vst2.Parameter{
-			Name:  "Gain",
-			Unit:  "db",
-			Value: 0.5,
+			Name:    "Gain",
+			Unit:    "db",
+			Default: 0.5,
 			GetValueLabelFunc: func(value float32) string {
 				return fmt.Sprintf("%+.2f", value)
 			},
@@ -39,7 +39,7 @@ func init() {
 			},
 			ProcessDoubleFunc: func(in, out vst2.DoubleBuffer) {
 				var g = math.Pow(10, float64(gain.GetValue())/20)
-				for c := 0; c < channels; c++ {
+				for c := 0; c < out.Channels(); c++ {
 					for i := 0; i < in.Frames; i++ {
 						out.Channel(c)[i] = in.Channel(c)[i] * g
 					}
@@ -47,7 +47,7 @@ func init() {
 			},
 			ProcessFloatFunc: func(in, out vst2.FloatBuffer) {
 				var g = math.Pow(10, float64(gain.GetValue())/20)
-				for c := 0; c < channels; c++ {
+				for c := 0; c < out.Channels(); c++ {
 					for i := 0; i < in.Frames; i++ {
 						out.Channel(c)[i] = in.Channel(c)[i] * float32(g)
 					}
@@ -0,0 +1,9 @@
+// +build !plugin
+
+package vst2
+
+// InstanceCallback returns callback of the instance host, it allows to
+// simulate changes made in the instance editor.
+func InstanceCallback(p *MultiProcessor, instance int) HostCallbackFunc {
+	return p.instanceHost(instance).Callback()
+}
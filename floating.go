@@ -0,0 +1,181 @@
+package vst2
+
+// #include <stdlib.h>
+import "C"
+import (
+	"unsafe"
+
+	"pipelined.dev/signal"
+)
+
+type (
+	// doubleFloating is signal.Floating view of DoubleBuffer. Samples are
+	// accessed directly in C memory with interleaved indices.
+	doubleFloating struct {
+		b DoubleBuffer
+	}
+
+	// floatFloating is signal.Floating view of FloatBuffer. Samples are
+	// accessed directly in C memory with interleaved indices.
+	floatFloating struct {
+		b FloatBuffer
+	}
+)
+
+// Floating returns signal.Floating view of the buffer. The view doesn't
+// copy samples, but reads and writes them in the buffer memory. It has
+// fixed length, so Append and AppendSample cause panic.
+func (b DoubleBuffer) Floating() signal.Floating {
+	return doubleFloating{b: b}
+}
+
+// Capacity returns capacity of a single channel.
+func (s doubleFloating) Capacity() int {
+	return s.b.Frames
+}
+
+// Channels returns number of channels in the buffer.
+func (s doubleFloating) Channels() int {
+	return len(s.b.data)
+}
+
+// Length returns length of a single channel.
+func (s doubleFloating) Length() int {
+	return s.b.Frames
+}
+
+// Len returns length of whole buffer.
+func (s doubleFloating) Len() int {
+	return s.b.Frames * len(s.b.data)
+}
+
+// Cap returns capacity of whole buffer.
+func (s doubleFloating) Cap() int {
+	return s.Len()
+}
+
+// BufferIndex calculates interleaved sample index.
+func (s doubleFloating) BufferIndex(channel, idx int) int {
+	return len(s.b.data)*idx + channel
+}
+
+// Free does nothing, memory is owned by the buffer.
+func (s doubleFloating) Free(*signal.PoolAllocator) {}
+
+// Slice returns view of frames [start, end).
+func (s doubleFloating) Slice(start, end int) signal.Floating {
+	var b DoubleBuffer
+	s.b.slice(&b, start, end)
+	return doubleFloating{b: b}
+}
+
+// Channel returns view of a single channel.
+func (s doubleFloating) Channel(c int) signal.Floating {
+	return doubleFloating{
+		b: DoubleBuffer{
+			Frames: s.b.Frames,
+			data:   s.b.data[c : c+1 : c+1],
+		},
+	}
+}
+
+// Append panics.
+func (s doubleFloating) Append(signal.Floating) {
+	panic("appending signal to the C buffer")
+}
+
+// AppendSample panics.
+func (s doubleFloating) AppendSample(float64) {
+	panic("appending sample to the C buffer")
+}
+
+// Sample returns signal value for provided interleaved index.
+func (s doubleFloating) Sample(i int) float64 {
+	channels := len(s.b.data)
+	return float64((*[1 << 30]C.double)(unsafe.Pointer(s.b.data[i%channels]))[i/channels])
+}
+
+// SetSample sets signal value for provided interleaved index.
+func (s doubleFloating) SetSample(i int, value float64) {
+	channels := len(s.b.data)
+	(*[1 << 30]C.double)(unsafe.Pointer(s.b.data[i%channels]))[i/channels] = C.double(value)
+}
+
+// Floating returns signal.Floating view of the buffer. The view doesn't
+// copy samples, but reads and writes them in the buffer memory. It has
+// fixed length, so Append and AppendSample cause panic.
+func (b FloatBuffer) Floating() signal.Floating {
+	return floatFloating{b: b}
+}
+
+// Capacity returns capacity of a single channel.
+func (s floatFloating) Capacity() int {
+	return s.b.Frames
+}
+
+// Channels returns number of channels in the buffer.
+func (s floatFloating) Channels() int {
+	return len(s.b.data)
+}
+
+// Length returns length of a single channel.
+func (s floatFloating) Length() int {
+	return s.b.Frames
+}
+
+// Len returns length of whole buffer.
+func (s floatFloating) Len() int {
+	return s.b.Frames * len(s.b.data)
+}
+
+// Cap returns capacity of whole buffer.
+func (s floatFloating) Cap() int {
+	return s.Len()
+}
+
+// BufferIndex calculates interleaved sample index.
+func (s floatFloating) BufferIndex(channel, idx int) int {
+	return len(s.b.data)*idx + channel
+}
+
+// Free does nothing, memory is owned by the buffer.
+func (s floatFloating) Free(*signal.PoolAllocator) {}
+
+// Slice returns view of frames [start, end).
+func (s floatFloating) Slice(start, end int) signal.Floating {
+	var b FloatBuffer
+	s.b.slice(&b, start, end)
+	return floatFloating{b: b}
+}
+
+// Channel returns view of a single channel.
+func (s floatFloating) Channel(c int) signal.Floating {
+	return floatFloating{
+		b: FloatBuffer{
+			Frames: s.b.Frames,
+			data:   s.b.data[c : c+1 : c+1],
+		},
+	}
+}
+
+// Append panics.
+func (s floatFloating) Append(signal.Floating) {
+	panic("appending signal to the C buffer")
+}
+
+// AppendSample panics.
+func (s floatFloating) AppendSample(float64) {
+	panic("appending sample to the C buffer")
+}
+
+// Sample returns signal value for provided interleaved index.
+func (s floatFloating) Sample(i int) float64 {
+	channels := len(s.b.data)
+	return float64((*[1 << 30]C.float)(unsafe.Pointer(s.b.data[i%channels]))[i/channels])
+}
+
+// SetSample sets signal value for provided interleaved index.
+func (s floatFloating) SetSample(i int, value float64) {
+	channels := len(s.b.data)
+	(*[1 << 30]C.float)(unsafe.Pointer(s.b.data[i%channels]))[i/channels] = C.float(value)
+}
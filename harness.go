@@ -0,0 +1,313 @@
+// +build plugin,harness
+
+package vst2
+
+//#include "include/harness/harness.c"
+import "C"
+import (
+	"errors"
+	"sync"
+	"unsafe"
+
+	"pipelined.dev/signal"
+)
+
+var (
+	// global state for harness callbacks.
+	harnesses = struct {
+		sync.RWMutex
+		mapping map[uintptr]HostCallbackFunc
+	}{
+		mapping: map[uintptr]HostCallbackFunc{},
+	}
+)
+
+// Harness drives Go plugin in the same process through the C bridge, the
+// same way host does it. It's only built with harness tag, so plugin
+// binaries don't include it. It allows to test plugins with
+// go test -tags "plugin harness":
+//
+//	h, err := vst2.NewHarness(allocator, vst2.Host{})
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer h.Close()
+//	h.SetParamValue(0, 1)
+//	out := h.ProcessDouble([][]float64{{1, 1}, {1, 1}}, 2)
+type Harness struct {
+	p *C.CPlugin
+	// plugin is kept to report its state after it's closed.
+	plugin *Plugin
+}
+
+// NewHarness instantiates Go plugin with provided allocator. Calls from
+// plugin to host are handled by provided mock host. If allocator is nil,
+// plugin is allocated the same way as in the host: PluginAllocator is
+// used or, if plugins are registered, plugin is selected by the unique ID
+// that mock host returns with GetCurrentID.
+func NewHarness(allocator PluginAllocatorFunc, h Host) (harness *Harness, err error) {
+	cp := C.newHarnessPlugin()
+	harnesses.Lock()
+	harnesses.mapping[uintptr(unsafe.Pointer(cp))] = h.Callback()
+	harnesses.Unlock()
+	defer func() {
+		if r := recover(); r != nil {
+			harnesses.Lock()
+			delete(harnesses.mapping, uintptr(unsafe.Pointer(cp)))
+			harnesses.Unlock()
+			C.free(unsafe.Pointer(cp))
+			err = errors.New("failed to allocate plugin")
+		}
+	}()
+	handler := callbackHandler{C.harnessCallback()}
+	if allocator == nil {
+		allocator = handler.allocator(cp)
+	}
+	newPlugin(cp, handler.host(cp), allocator)
+	return &Harness{p: cp, plugin: getPlugin(cp)}, nil
+}
+
+// Dispatch calls plugin dispatcher.
+func (h *Harness) Dispatch(op PluginOpcode, index int32, value int64, ptr unsafe.Pointer, opt float32) int64 {
+	return int64(C.dispatchHarness(h.p, C.int32_t(op), C.int32_t(index), C.int64_t(value), ptr, C.float(opt)))
+}
+
+// Close closes the plugin and frees allocated memory.
+func (h *Harness) Close() {
+	h.Dispatch(plugClose, 0, 0, nil, 0)
+	harnesses.Lock()
+	delete(harnesses.mapping, uintptr(unsafe.Pointer(h.p)))
+	harnesses.Unlock()
+	C.free(unsafe.Pointer(h.p))
+}
+
+// Failed returns true if plugin recovered from panic and switched into
+// failure mode. It's also valid after plugin is closed.
+func (h *Harness) Failed() bool {
+	return h.plugin.isFailed()
+}
+
+// Flags returns the plugin flags.
+func (h *Harness) Flags() PluginFlag {
+	return PluginFlag(h.p.flags)
+}
+
+// NumParams returns the number of parameters.
+func (h *Harness) NumParams() int {
+	return int(h.p.numParams)
+}
+
+// NumPrograms returns the number of programs.
+func (h *Harness) NumPrograms() int {
+	return int(h.p.numPrograms)
+}
+
+// NumInputs returns the number of inputs.
+func (h *Harness) NumInputs() int {
+	return int(h.p.numInputs)
+}
+
+// NumOutputs returns the number of outputs.
+func (h *Harness) NumOutputs() int {
+	return int(h.p.numOutputs)
+}
+
+// Start executes the PlugOpen opcode.
+func (h *Harness) Start() {
+	h.Dispatch(plugOpen, 0, 0, nil, 0)
+}
+
+// Resume the plugin processing.
+func (h *Harness) Resume() {
+	h.Dispatch(plugStateChanged, 0, 1, nil, 0)
+}
+
+// Suspend the plugin processing.
+func (h *Harness) Suspend() {
+	h.Dispatch(plugStateChanged, 0, 0, nil, 0)
+}
+
+// SetBufferSize sets a buffer size per channel.
+func (h *Harness) SetBufferSize(bufferSize int) {
+	h.Dispatch(plugSetBufferSize, 0, int64(bufferSize), nil, 0)
+}
+
+// SetSampleRate sets a sample rate for plugin.
+func (h *Harness) SetSampleRate(sampleRate signal.Frequency) {
+	h.Dispatch(plugSetSampleRate, 0, 0, nil, float32(sampleRate))
+}
+
+// CanDo queries plugin capability.
+func (h *Harness) CanDo(s PluginCanDoString) CanDoResponse {
+	cs := C.CString(string(s))
+	defer C.free(unsafe.Pointer(cs))
+	return CanDoResponse(h.Dispatch(PlugCanDo, 0, 0, unsafe.Pointer(cs), 0))
+}
+
+// ParamValue returns the value of parameter.
+func (h *Harness) ParamValue(index int) float32 {
+	return float32(C.getParameterHarness(h.p, C.int32_t(index)))
+}
+
+// SetParamValue sets new value for parameter.
+func (h *Harness) SetParamValue(index int, value float32) {
+	C.setParameterHarness(h.p, C.int32_t(index), C.float(value))
+}
+
+// ParamName returns the parameter label.
+func (h *Harness) ParamName(index int) string {
+	var s ascii8
+	h.Dispatch(plugGetParamName, int32(index), 0, unsafe.Pointer(&s), 0)
+	return s.String()
+}
+
+// ParamValueName returns the parameter value label.
+func (h *Harness) ParamValueName(index int) string {
+	var s ascii8
+	h.Dispatch(plugGetParamDisplay, int32(index), 0, unsafe.Pointer(&s), 0)
+	return s.String()
+}
+
+// ParamUnitName returns the parameter unit label.
+func (h *Harness) ParamUnitName(index int) string {
+	var s ascii8
+	h.Dispatch(plugGetParamLabel, int32(index), 0, unsafe.Pointer(&s), 0)
+	return s.String()
+}
+
+// ParamProperties returns parameter properties for provided parameter
+// index. If plugin doesn't provide them, boolean result is false.
+func (h *Harness) ParamProperties(index int) (*ParameterProperties, bool) {
+	var props ParameterProperties
+	if h.Dispatch(plugGetParameterProperties, int32(index), 0, unsafe.Pointer(&props), 0) > 0 {
+		return &props, true
+	}
+	return nil, false
+}
+
+// Program returns current program number.
+func (h *Harness) Program() int {
+	return int(h.Dispatch(plugGetProgram, 0, 0, nil, 0))
+}
+
+// SetProgram changes current program index.
+func (h *Harness) SetProgram(index int) {
+	h.Dispatch(plugSetProgram, 0, int64(index), nil, 0)
+}
+
+// ProgramName returns program name for provided program index.
+func (h *Harness) ProgramName(index int) string {
+	var s ascii24
+	h.Dispatch(plugGetProgramNameIndexed, int32(index), 0, unsafe.Pointer(&s), 0)
+	return s.String()
+}
+
+// GetChunk returns plugin state. Preset state is returned if isPreset is
+// true, bank state otherwise.
+func (h *Harness) GetChunk(isPreset bool) []byte {
+	var ptr unsafe.Pointer
+	length := h.Dispatch(plugGetChunk, boolIndex(isPreset), 0, unsafe.Pointer(&ptr), 0)
+	if length == 0 {
+		return nil
+	}
+	defer C.free(ptr)
+	return C.GoBytes(ptr, C.int(length))
+}
+
+// SetChunk sets plugin state. Preset state is set if isPreset is true,
+// bank state otherwise.
+func (h *Harness) SetChunk(data []byte, isPreset bool) {
+	ptr := C.CBytes(data)
+	defer C.free(ptr)
+	h.Dispatch(plugSetChunk, boolIndex(isPreset), int64(len(data)), ptr, 0)
+}
+
+// InputProperties returns properties of input pin. If plugin doesn't
+// provide them, boolean result is false.
+func (h *Harness) InputProperties(index int) (*PinProperties, bool) {
+	var props PinProperties
+	if h.Dispatch(PlugGetInputProperties, int32(index), 0, unsafe.Pointer(&props), 0) > 0 {
+		return &props, true
+	}
+	return nil, false
+}
+
+// OutputProperties returns properties of output pin. If plugin doesn't
+// provide them, boolean result is false.
+func (h *Harness) OutputProperties(index int) (*PinProperties, bool) {
+	var props PinProperties
+	if h.Dispatch(PlugGetOutputProperties, int32(index), 0, unsafe.Pointer(&props), 0) > 0 {
+		return &props, true
+	}
+	return nil, false
+}
+
+// SetSpeakerArrangement proposes speaker arrangement to the plugin.
+// Returns false if plugin rejects it. Arrangements are passed in C
+// memory, because input is passed as integer value.
+func (h *Harness) SetSpeakerArrangement(in, out SpeakerArrangement) bool {
+	size := C.size_t(unsafe.Sizeof(SpeakerArrangement{}))
+	cin, cout := (*SpeakerArrangement)(C.malloc(size)), (*SpeakerArrangement)(C.malloc(size))
+	defer C.free(unsafe.Pointer(cin))
+	defer C.free(unsafe.Pointer(cout))
+	*cin, *cout = in, out
+	return h.Dispatch(plugSetSpeakerArrangement, 0, int64(uintptr(unsafe.Pointer(cin))), unsafe.Pointer(cout), 0) > 0
+}
+
+// SpeakerArrangement returns current speaker arrangement of the plugin.
+func (h *Harness) SpeakerArrangement() (in, out SpeakerArrangement) {
+	ptrs := (*[2]*SpeakerArrangement)(C.calloc(2, C.size_t(unsafe.Sizeof(uintptr(0)))))
+	defer C.free(unsafe.Pointer(ptrs))
+	h.Dispatch(PlugGetSpeakerArrangement, 0, int64(uintptr(unsafe.Pointer(&ptrs[0]))), unsafe.Pointer(&ptrs[1]), 0)
+	return *ptrs[0], *ptrs[1]
+}
+
+// ProcessEvents passes events to the plugin.
+func (h *Harness) ProcessEvents(events ...Event) {
+	e := Events(events...)
+	defer e.Free()
+	h.Dispatch(PlugProcessEvents, 0, 0, unsafe.Pointer(e), 0)
+}
+
+// ProcessDouble processes provided input with double precision and
+// returns the output. Input must have a slice for every plugin input.
+func (h *Harness) ProcessDouble(in [][]float64, frames int) [][]float64 {
+	cin := NewDoubleBuffer(h.NumInputs(), frames)
+	defer cin.Free()
+	cout := NewDoubleBuffer(h.NumOutputs(), frames)
+	defer cout.Free()
+	for c := range in {
+		copy(cin.Channel(c), in[c])
+	}
+	C.processDoubleHarness(h.p, cin.cArray(), cout.cArray(), C.int32_t(frames))
+	out := make([][]float64, h.NumOutputs())
+	for c := range out {
+		out[c] = append([]float64(nil), cout.Channel(c)...)
+	}
+	return out
+}
+
+// ProcessFloat processes provided input with float precision and returns
+// the output. Input must have a slice for every plugin input.
+func (h *Harness) ProcessFloat(in [][]float32, frames int) [][]float32 {
+	cin := NewFloatBuffer(h.NumInputs(), frames)
+	defer cin.Free()
+	cout := NewFloatBuffer(h.NumOutputs(), frames)
+	defer cout.Free()
+	for c := range in {
+		copy(cin.Channel(c), in[c])
+	}
+	C.processFloatHarness(h.p, cin.cArray(), cout.cArray(), C.int32_t(frames))
+	out := make([][]float32, h.NumOutputs())
+	for c := range out {
+		out[c] = append([]float32(nil), cout.Channel(c)...)
+	}
+	return out
+}
+
+func boolIndex(b bool) int32 {
+	if b {
+		return 1
+	}
+	return 0
+}
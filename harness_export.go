@@ -0,0 +1,22 @@
+// +build plugin,harness
+
+package vst2
+
+//#include "include/vst.h"
+import "C"
+import "unsafe"
+
+//export harnessCallbackBridge
+// global harness callback, calls mock host callback.
+func harnessCallbackBridge(p *C.CPlugin, opcode int32, index int32, value int64, ptr unsafe.Pointer, opt float32) int64 {
+	if HostOpcode(opcode) == HostVersion {
+		return 2400
+	}
+	harnesses.RLock()
+	c, ok := harnesses.mapping[uintptr(unsafe.Pointer(p))]
+	harnesses.RUnlock()
+	if !ok {
+		panic("plugin was closed")
+	}
+	return c(HostOpcode(opcode), index, value, ptr, opt)
+}
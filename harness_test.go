@@ -0,0 +1,356 @@
+// +build plugin,harness
+
+package vst2_test
+
+import (
+	"testing"
+
+	"pipelined.dev/audio/vst2"
+)
+
+func TestHarness(t *testing.T) {
+	allocator := func(h vst2.Host) (vst2.Plugin, vst2.Dispatcher) {
+		gain := &vst2.Parameter{
+			Name:    "Gain",
+			Unit:    "x",
+			Default: 0.5,
+		}
+		return vst2.Plugin{
+			Name:           "Test",
+			InputChannels:  1,
+			OutputChannels: 1,
+			Parameters:     []*vst2.Parameter{gain},
+			Programs: []*vst2.Program{
+				{Name: "Half", Values: []float32{0.5}},
+				{Name: "Full", Values: []float32{1}},
+			},
+			ProcessDoubleFunc: func(in, out vst2.DoubleBuffer) {
+				if gain.Value() == 0 {
+					panic("zero gain")
+				}
+				for i := 0; i < in.Frames; i++ {
+					out.Channel(0)[i] = in.Channel(0)[i] * float64(gain.Value())
+				}
+			},
+		}, vst2.Dispatcher{
+			SetBypassFunc: func(bool) bool { return true },
+		}
+	}
+	testHarness := func(fn func(t *testing.T, h *vst2.Harness)) func(*testing.T) {
+		return func(t *testing.T) {
+			h, err := vst2.NewHarness(allocator, vst2.Host{})
+			assertEqual(t, "harness error", err, nil)
+			defer h.Close()
+			fn(t, h)
+		}
+	}
+	t.Run("properties", testHarness(func(t *testing.T, h *vst2.Harness) {
+		assertEqual(t, "num params", h.NumParams(), 1)
+		assertEqual(t, "num programs", h.NumPrograms(), 2)
+		assertEqual(t, "param name", h.ParamName(0), "Gain")
+		assertEqual(t, "param unit", h.ParamUnitName(0), "x")
+		assertEqual(t, "double processing", h.Flags()&vst2.PluginDoubleProcessing, vst2.PluginDoubleProcessing)
+		assertEqual(t, "can bypass", h.CanDo(vst2.PluginCanBypass), vst2.YesCanDo)
+		assertEqual(t, "can send events", h.CanDo(vst2.PluginCanSendMIDIEvent), vst2.YesCanDo)
+	}))
+	t.Run("parameter out of range", testHarness(func(t *testing.T, h *vst2.Harness) {
+		assertEqual(t, "param name", h.ParamName(1), "")
+		assertEqual(t, "param display", h.ParamValueName(-1), "")
+		assertEqual(t, "param unit", h.ParamUnitName(1), "")
+		_, ok := h.ParamProperties(1)
+		assertEqual(t, "param properties", ok, false)
+		assertEqual(t, "can be automated", h.Dispatch(vst2.PlugCanBeAutomated, 1, 0, nil, 0), int64(0))
+		h.SetParamValue(1, 1)
+		assertEqual(t, "param value", h.ParamValue(1), float32(0))
+		assertEqual(t, "failed", h.Failed(), false)
+		_, ok = h.ParamProperties(0)
+		assertEqual(t, "valid param properties", ok, true)
+	}))
+	t.Run("programs", testHarness(func(t *testing.T, h *vst2.Harness) {
+		assertEqual(t, "program name", h.ProgramName(1), "Full")
+		h.SetProgram(1)
+		assertEqual(t, "program", h.Program(), 1)
+		assertEqual(t, "param value", h.ParamValue(0), float32(1))
+	}))
+	t.Run("chunk", testHarness(func(t *testing.T, h *vst2.Harness) {
+		h.SetParamValue(0, 0.25)
+		chunk := h.GetChunk(true)
+		h.SetParamValue(0, 1)
+		h.SetChunk(chunk, true)
+		assertEqual(t, "param value", h.ParamValue(0), float32(0.25))
+	}))
+	t.Run("process", testHarness(func(t *testing.T, h *vst2.Harness) {
+		out := h.ProcessDouble([][]float64{{1, 2}}, 2)
+		assertEqual(t, "output", out, [][]float64{{0.5, 1}})
+	}))
+	t.Run("panic", testHarness(func(t *testing.T, h *vst2.Harness) {
+		logger := vst2.PluginLogger
+		vst2.PluginLogger = nil
+		defer func() { vst2.PluginLogger = logger }()
+		h.SetParamValue(0, 0)
+		out := h.ProcessDouble([][]float64{{1, 2}}, 2)
+		assertEqual(t, "failed", h.Failed(), true)
+		assertEqual(t, "output", out, [][]float64{{0, 0}})
+		assertEqual(t, "ignored dispatch", h.ParamName(0), "")
+	}))
+	t.Run("close failed", func(t *testing.T) {
+		logger := vst2.PluginLogger
+		vst2.PluginLogger = nil
+		defer func() { vst2.PluginLogger = logger }()
+		var closed bool
+		h, err := vst2.NewHarness(func(h vst2.Host) (vst2.Plugin, vst2.Dispatcher) {
+			return vst2.Plugin{
+				ProcessDoubleFunc: func(in, out vst2.DoubleBuffer) {
+					panic("process")
+				},
+			}, vst2.Dispatcher{
+				CloseFunc: func() {
+					closed = true
+					panic("close")
+				},
+			}
+		}, vst2.Host{})
+		assertEqual(t, "harness error", err, nil)
+		h.ProcessDouble(nil, 1)
+		assertEqual(t, "failed", h.Failed(), true)
+		h.Close()
+		assertEqual(t, "closed", closed, true)
+		assertEqual(t, "failed after close", h.Failed(), true)
+	})
+}
+
+func TestChunkDispatch(t *testing.T) {
+	allocator := func(d vst2.Dispatcher) vst2.PluginAllocatorFunc {
+		return func(h vst2.Host) (vst2.Plugin, vst2.Dispatcher) {
+			return vst2.Plugin{
+				Version:    2,
+				Parameters: []*vst2.Parameter{{Name: "Gain"}},
+				Programs: []*vst2.Program{
+					{Name: "Half", Values: []float32{0.5}},
+					{Name: "Full", Values: []float32{1}},
+				},
+			}, d
+		}
+	}
+	newHarness := func(t *testing.T, d vst2.Dispatcher) *vst2.Harness {
+		t.Helper()
+		h, err := vst2.NewHarness(allocator(d), vst2.Host{})
+		assertEqual(t, "harness error", err, nil)
+		return h
+	}
+	marshal := func(t *testing.T, c vst2.Chunk) []byte {
+		t.Helper()
+		data, err := c.MarshalBinary()
+		assertEqual(t, "marshal error", err, nil)
+		return data
+	}
+	t.Run("flags", func(t *testing.T) {
+		h := newHarness(t, vst2.Dispatcher{})
+		defer h.Close()
+		assertEqual(t, "default", h.Flags()&vst2.PluginProgramChunks, vst2.PluginFlag(0))
+		h = newHarness(t, vst2.Dispatcher{GetStateFunc: func() []byte { return nil }})
+		defer h.Close()
+		assertEqual(t, "state", h.Flags()&vst2.PluginProgramChunks, vst2.PluginProgramChunks)
+	})
+	t.Run("migrate", func(t *testing.T) {
+		var migrated []int32
+		h := newHarness(t, vst2.Dispatcher{
+			MigrateChunkFunc: func(c *vst2.Chunk) {
+				migrated = append(migrated, c.Version)
+				c.Values[0] /= 2
+			},
+		})
+		defer h.Close()
+		h.SetChunk(marshal(t, vst2.Chunk{Version: 1, Values: []float32{0.5}}), true)
+		assertEqual(t, "migrated", migrated, []int32{1})
+		assertEqual(t, "migrated value", h.ParamValue(0), float32(0.25))
+		h.SetChunk(marshal(t, vst2.Chunk{Version: 2, Values: []float32{0.5}}), true)
+		assertEqual(t, "current version", migrated, []int32{1})
+		assertEqual(t, "current value", h.ParamValue(0), float32(0.5))
+	})
+	t.Run("state", func(t *testing.T) {
+		var restored []byte
+		h := newHarness(t, vst2.Dispatcher{
+			GetStateFunc: func() []byte { return []byte("state") },
+			SetStateFunc: func(state []byte) { restored = state },
+		})
+		defer h.Close()
+		var c vst2.Chunk
+		assertEqual(t, "unmarshal error", c.UnmarshalBinary(h.GetChunk(true)), nil)
+		assertEqual(t, "saved state", c.State, []byte("state"))
+		h.SetChunk(marshal(t, vst2.Chunk{Version: 2, State: []byte("restored")}), true)
+		assertEqual(t, "restored state", restored, []byte("restored"))
+	})
+	t.Run("bank", func(t *testing.T) {
+		h := newHarness(t, vst2.Dispatcher{})
+		defer h.Close()
+		h.SetProgram(1)
+		h.SetParamValue(0, 0.75)
+		bank := h.GetChunk(false)
+		var c vst2.Chunk
+		assertEqual(t, "unmarshal error", c.UnmarshalBinary(bank), nil)
+		assertEqual(t, "program", c.Program, 1)
+		assertEqual(t, "programs", c.Programs, []vst2.Program{
+			{Name: "Half", Values: []float32{0.5}},
+			{Name: "Full", Values: []float32{0.75}},
+		})
+
+		loaded := newHarness(t, vst2.Dispatcher{})
+		defer loaded.Close()
+		loaded.SetChunk(bank, false)
+		assertEqual(t, "loaded program", loaded.Program(), 1)
+		assertEqual(t, "loaded value", loaded.ParamValue(0), float32(0.75))
+		loaded.SetProgram(0)
+		assertEqual(t, "other program", loaded.ParamValue(0), float32(0.5))
+		loaded.SetProgram(1)
+		assertEqual(t, "saved program", loaded.ParamValue(0), float32(0.75))
+	})
+}
+
+func TestPrecisionBridge(t *testing.T) {
+	double := func(h vst2.Host) (vst2.Plugin, vst2.Dispatcher) {
+		return vst2.Plugin{
+			InputChannels:  1,
+			OutputChannels: 1,
+			ProcessDoubleFunc: func(in, out vst2.DoubleBuffer) {
+				for i := 0; i < in.Frames; i++ {
+					out.Channel(0)[i] = in.Channel(0)[i] * 2
+				}
+			},
+		}, vst2.Dispatcher{}
+	}
+	float := func(h vst2.Host) (vst2.Plugin, vst2.Dispatcher) {
+		return vst2.Plugin{
+			InputChannels:  1,
+			OutputChannels: 1,
+			ProcessFloatFunc: func(in, out vst2.FloatBuffer) {
+				for i := 0; i < in.Frames; i++ {
+					out.Channel(0)[i] = in.Channel(0)[i] * 2
+				}
+			},
+		}, vst2.Dispatcher{}
+	}
+	testBridge := func(allocator vst2.PluginAllocatorFunc) func(*testing.T) {
+		return func(t *testing.T) {
+			h, err := vst2.NewHarness(allocator, vst2.Host{})
+			assertEqual(t, "harness error", err, nil)
+			defer h.Close()
+			flags := vst2.PluginFloatProcessing | vst2.PluginDoubleProcessing
+			assertEqual(t, "flags", h.Flags()&flags, flags)
+			h.SetBufferSize(2)
+			assertEqual(t, "double", h.ProcessDouble([][]float64{{1, 2}}, 2), [][]float64{{2, 4}})
+			assertEqual(t, "float", h.ProcessFloat([][]float32{{1, 2}}, 2), [][]float32{{2, 4}})
+			// host passes more frames than announced.
+			assertEqual(t, "double grow", h.ProcessDouble([][]float64{{1, 2, 3}}, 3), [][]float64{{2, 4, 6}})
+			assertEqual(t, "float grow", h.ProcessFloat([][]float32{{1, 2, 3}}, 3), [][]float32{{2, 4, 6}})
+		}
+	}
+	t.Run("double only", testBridge(double))
+	t.Run("float only", testBridge(float))
+}
+
+func TestBuses(t *testing.T) {
+	allocator := func(h vst2.Host) (vst2.Plugin, vst2.Dispatcher) {
+		pins := []vst2.Pin{
+			{Label: "Left", IsStereo: true},
+			{Label: "Right"},
+			{Label: "Sidechain", Sidechain: true},
+		}
+		p := vst2.Plugin{
+			InputChannels:  3,
+			OutputChannels: 2,
+			InputPins:      pins,
+		}
+		assertEqual(t, "main channels", vst2.MainInputChannels(pins, p.InputChannels), 2)
+		assertEqual(t, "sidechain channels", vst2.SidechainChannels(pins, p.InputChannels), 1)
+		p.ProcessDoubleFunc = func(in, out vst2.DoubleBuffer) {
+			b := vst2.SplitDoubleBuses(pins, in, out)
+			for c := 0; c < b.Output.Channels(); c++ {
+				for i := 0; i < b.Output.Frames; i++ {
+					b.Output.Channel(c)[i] = b.Main.Channel(c)[i] * b.Sidechain.Channel(0)[i]
+				}
+			}
+		}
+		return p, vst2.Dispatcher{}
+	}
+	h, err := vst2.NewHarness(allocator, vst2.Host{})
+	assertEqual(t, "harness error", err, nil)
+	defer h.Close()
+	out := h.ProcessDouble([][]float64{{1, 2}, {3, 4}, {0.5, 0}}, 2)
+	assertEqual(t, "output", out, [][]float64{{0.5, 0}, {1.5, 0}})
+	out32 := h.ProcessFloat([][]float32{{1, 2}, {3, 4}, {0.5, 0}}, 2)
+	assertEqual(t, "bridged output", out32, [][]float32{{0.5, 0}, {1.5, 0}})
+}
+
+func TestPins(t *testing.T) {
+	allocator := func(reject bool) vst2.PluginAllocatorFunc {
+		return func(h vst2.Host) (vst2.Plugin, vst2.Dispatcher) {
+			d := vst2.Dispatcher{}
+			if reject {
+				d.SetSpeakerArrangementFunc = func(in, out vst2.SpeakerArrangement) bool {
+					return false
+				}
+			}
+			return vst2.Plugin{
+				InputChannels:  2,
+				OutputChannels: 2,
+				InputPins: []vst2.Pin{
+					{Label: "Left", IsStereo: true},
+					{Label: "Right"},
+				},
+				OutputPins: []vst2.Pin{
+					{Label: "Out", UseSpeaker: true, Arrangement: vst2.SpeakerArrMono},
+				},
+				ProcessDoubleFunc: func(in, out vst2.DoubleBuffer) {},
+			}, d
+		}
+	}
+	testPins := func(reject bool, fn func(t *testing.T, h *vst2.Harness)) func(*testing.T) {
+		return func(t *testing.T) {
+			h, err := vst2.NewHarness(allocator(reject), vst2.Host{})
+			assertEqual(t, "harness error", err, nil)
+			defer h.Close()
+			fn(t, h)
+		}
+	}
+	stereo := vst2.SpeakerArrangement{Type: vst2.SpeakerArrStereo, NumChannels: 2}
+	mono := vst2.SpeakerArrangement{Type: vst2.SpeakerArrMono, NumChannels: 1}
+	t.Run("pin properties", testPins(false, func(t *testing.T, h *vst2.Harness) {
+		left, ok := h.InputProperties(0)
+		assertEqual(t, "left ok", ok, true)
+		assertEqual(t, "left label", left.Label.String(), "Left")
+		assertEqual(t, "left flags", left.Flags, vst2.PinIsActive|vst2.PinIsStereo)
+		right, ok := h.InputProperties(1)
+		assertEqual(t, "right ok", ok, true)
+		assertEqual(t, "right flags", right.Flags, vst2.PinIsActive)
+		out, ok := h.OutputProperties(0)
+		assertEqual(t, "out ok", ok, true)
+		assertEqual(t, "out flags", out.Flags, vst2.PinIsActive|vst2.PinUseSpeaker)
+		assertEqual(t, "out arrangement", out.SpeakerArrangementType, vst2.SpeakerArrMono)
+		_, ok = h.InputProperties(2)
+		assertEqual(t, "missing input", ok, false)
+		_, ok = h.OutputProperties(-1)
+		assertEqual(t, "missing output", ok, false)
+	}))
+	t.Run("accepted arrangement", testPins(false, func(t *testing.T, h *vst2.Harness) {
+		in, out := h.SpeakerArrangement()
+		assertEqual(t, "default input", in.Type, vst2.SpeakerArrStereo)
+		assertEqual(t, "default output", out.NumChannels, int32(2))
+		custom := vst2.SpeakerArrangement{Type: vst2.SpeakerArrStereoSurround, NumChannels: 2}
+		assertEqual(t, "accepted", h.SetSpeakerArrangement(custom, stereo), true)
+		in, _ = h.SpeakerArrangement()
+		assertEqual(t, "applied", in.Type, vst2.SpeakerArrStereoSurround)
+	}))
+	t.Run("rejected arrangement", testPins(false, func(t *testing.T, h *vst2.Harness) {
+		assertEqual(t, "channels mismatch", h.SetSpeakerArrangement(mono, stereo), false)
+		in, _ := h.SpeakerArrangement()
+		assertEqual(t, "kept", in, stereo)
+	}))
+	t.Run("rejected by plugin", testPins(true, func(t *testing.T, h *vst2.Harness) {
+		assertEqual(t, "rejected", h.SetSpeakerArrangement(stereo, stereo), false)
+	}))
+	t.Run("nil arrangement", testPins(false, func(t *testing.T, h *vst2.Harness) {
+		assertEqual(t, "get", h.Dispatch(vst2.PlugGetSpeakerArrangement, 0, 0, nil, 0), int64(0))
+		assertEqual(t, "failed", h.Failed(), false)
+	}))
+}
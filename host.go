@@ -1,6 +1,11 @@
 package vst2
 
-import "pipelined.dev/signal"
+import (
+	"math"
+	"unsafe"
+
+	"pipelined.dev/signal"
+)
 
 type (
 	// Host handles all callbacks from plugin.
@@ -9,7 +14,11 @@ type (
 		GetBufferSize   HostGetBufferSizeFunc
 		GetProcessLevel HostGetProcessLevelFunc
 		GetTimeInfo     HostGetTimeInfoFunc
+		GetTempoAt      HostGetTempoAtFunc
 		UpdateDisplay   HostUpdateDisplayFunc
+		ProcessEvents   HostProcessEventsFunc
+		Automate        HostAutomateFunc
+		GetCurrentID    HostGetCurrentIDFunc
 	}
 
 	// HostGetSampleRateFunc returns host sample rate.
@@ -18,8 +27,87 @@ type (
 	HostGetBufferSizeFunc func() int
 	// HostGetProcessLevel returns the context of execution.
 	HostGetProcessLevelFunc func() ProcessLevel
-	// HostGetTimeInfo returns current time info.
+	// HostGetTimeInfo returns current time info. Returned value is
+	// passed to plugin, so it must be allocated in C memory and stay
+	// valid until the next call.
 	HostGetTimeInfoFunc func(flags TimeInfoFlag) *TimeInfo
+	// HostGetTempoAtFunc returns tempo in BPM at provided position in
+	// samples.
+	HostGetTempoAtFunc func(samplePos int) float64
 	// HostUpdateDisplay tells there are changes & requests GUI redraw. Returns true on success
 	HostUpdateDisplayFunc func() bool
+	// HostProcessEventsFunc passes events (e.g. MIDI events) from plugin
+	// to host. Events are only valid during the call. Returns true if
+	// events were processed.
+	HostProcessEventsFunc func(events ...Event) bool
+	// HostAutomateFunc is called when parameter value is changed by
+	// plugin, e.g. in plugin editor.
+	HostAutomateFunc func(index int, value float32)
+	// HostGetCurrentIDFunc returns unique ID of the plugin that host
+	// loads from shell. Zero ID means that shell itself is loaded.
+	HostGetCurrentIDFunc func() [4]byte
+)
+
+type (
+	// HostCallbackFunc used as callback function called by plugin. Use
+	// closure wrapping technique to add more types to callback.
+	HostCallbackFunc func(op HostOpcode, index int32, value int64, ptr unsafe.Pointer, opt float32) int64
 )
+
+// Callback returns HostCallbackFunc that handles all vst types casts
+// and allows to write handlers without usage of unsafe package.
+func (h Host) Callback() HostCallbackFunc {
+	return func(op HostOpcode, index int32, value int64, ptr unsafe.Pointer, opt float32) int64 {
+		switch op {
+		case HostGetCurrentProcessLevel:
+			if h.GetProcessLevel != nil {
+				return int64(h.GetProcessLevel())
+			}
+		case HostGetSampleRate:
+			if h.GetSampleRate != nil {
+				return int64(h.GetSampleRate())
+			}
+		case HostGetBufferSize:
+			if h.GetBufferSize != nil {
+				return int64(h.GetBufferSize())
+			}
+		case HostGetTime:
+			if h.GetTimeInfo != nil {
+				return int64(uintptr(unsafe.Pointer(h.GetTimeInfo(TimeInfoFlag(value)))))
+			}
+		case hostTempoAt:
+			if h.GetTempoAt != nil {
+				return int64(math.Round(h.GetTempoAt(int(value)) * 10000))
+			}
+		case HostUpdateDisplay:
+			if h.UpdateDisplay != nil && h.UpdateDisplay() {
+				return 1
+			}
+		case HostProcessEvents:
+			if h.ProcessEvents != nil {
+				e := (*EventsPtr)(ptr)
+				events := make([]Event, 0, e.NumEvents())
+				for i := 0; i < e.NumEvents(); i++ {
+					events = append(events, e.Event(i))
+				}
+				if h.ProcessEvents(events...) {
+					return 1
+				}
+			}
+		case HostAutomate:
+			if h.Automate != nil {
+				h.Automate(int(index), opt)
+			}
+		case HostCurrentID:
+			if h.GetCurrentID != nil {
+				return int64(uniqueID(h.GetCurrentID()))
+			}
+		}
+		return 0
+	}
+}
+
+// uniqueID converts unique ID bytes into integer value.
+func uniqueID(id [4]byte) int32 {
+	return int32(uint(id[0])<<24 | uint(id[1])<<16 | uint(id[2])<<8 | uint(id[3])<<0)
+}
@@ -20,6 +20,11 @@ var (
 	callbacks = struct {
 		sync.RWMutex
 		mapping map[unsafe.Pointer]HostCallbackFunc
+		// loading is callback of the plugin that is being loaded. Plugin
+		// can call host before its pointer is known, e.g. shell requests
+		// unique ID of the plugin to load. Plugins are loaded one by one.
+		loading HostCallbackFunc
+		load    sync.Mutex
 	}{
 		mapping: map[unsafe.Pointer]HostCallbackFunc{},
 	}
@@ -44,6 +49,10 @@ type (
 	// Plugin is an instance of loaded VST plugin.
 	Plugin struct {
 		p *C.CPlugin
+		// release frees resources that are bound to the plugin, e.g.
+		// memory returned to plugin by host callback. It's called when
+		// plugin is closed.
+		release func()
 	}
 
 	// pluginMain is a reference to VST main function.
@@ -51,38 +60,6 @@ type (
 	pluginMain C.EntryPoint
 )
 
-type (
-	// HostCallbackFunc used as callback function called by plugin. Use
-	// closure wrapping technique to add more types to callback.
-	HostCallbackFunc func(op HostOpcode, index int32, value int64, ptr unsafe.Pointer, opt float32) int64
-)
-
-// Callback returns HostCallbackFunc that handles all vst types casts
-// and allows to write handlers without usage of unsafe package.
-func (h Host) Callback() HostCallbackFunc {
-	return func(op HostOpcode, index int32, value int64, ptr unsafe.Pointer, opt float32) int64 {
-		switch op {
-		case HostGetCurrentProcessLevel:
-			if h.GetProcessLevel != nil {
-				return int64(h.GetProcessLevel())
-			}
-		case HostGetSampleRate:
-			if h.GetSampleRate != nil {
-				return int64(h.GetSampleRate())
-			}
-		case HostGetBufferSize:
-			if h.GetBufferSize != nil {
-				return int64(h.GetBufferSize())
-			}
-		case HostGetTime:
-			if h.GetTimeInfo != nil {
-				return int64(uintptr(unsafe.Pointer(h.GetTimeInfo(TimeInfoFlag(value)))))
-			}
-		}
-		return 0
-	}
-}
-
 // NoopHostCallback returns dummy host callback that just prints received
 // opcodes.
 func NoopHostCallback() HostCallbackFunc {
@@ -98,8 +75,14 @@ func (v *VST) Plugin(c HostCallbackFunc) *Plugin {
 	if v.main == nil || c == nil {
 		return nil
 	}
+	callbacks.load.Lock()
+	defer callbacks.load.Unlock()
+	callbacks.Lock()
+	callbacks.loading = c
+	callbacks.Unlock()
 	p := (*C.CPlugin)(C.loadPluginHostBridge(v.main))
 	callbacks.Lock()
+	callbacks.loading = nil
 	callbacks.mapping[unsafe.Pointer(p)] = c
 	callbacks.Unlock()
 
@@ -129,6 +112,22 @@ func (p *Plugin) NumPrograms() int {
 	return int(p.p.numPrograms)
 }
 
+// NumInputs returns the number of inputs.
+func (p *Plugin) NumInputs() int {
+	return int(p.p.numInputs)
+}
+
+// NumOutputs returns the number of outputs.
+func (p *Plugin) NumOutputs() int {
+	return int(p.p.numOutputs)
+}
+
+// InitialDelay returns the plugin latency in frames. It's valid after
+// plugin is resumed.
+func (p *Plugin) InitialDelay() int {
+	return int(p.p.initialDelay)
+}
+
 // Flags returns the plugin flags.
 func (p *Plugin) Flags() PluginFlag {
 	return PluginFlag(p.p.flags)
@@ -174,6 +173,17 @@ func (p *Plugin) CanProcessFloat64() bool {
 	return PluginFlag(p.p.flags)&PluginDoubleProcessing == PluginDoubleProcessing
 }
 
+// newTimeInfo allocates time info in C memory, so it can be returned to
+// plugin. It must be released with freeTimeInfo.
+func newTimeInfo() *TimeInfo {
+	return (*TimeInfo)(C.calloc(1, C.size_t(unsafe.Sizeof(TimeInfo{}))))
+}
+
+// freeTimeInfo releases C memory of time info.
+func freeTimeInfo(info *TimeInfo) {
+	C.free(unsafe.Pointer(info))
+}
+
 // Start executes the PlugOpen opcode.
 func (p *Plugin) Start() {
 	p.Dispatch(plugOpen, 0, 0, nil, 0.0)
@@ -183,8 +193,12 @@ func (p *Plugin) Start() {
 func (p *Plugin) Close() {
 	p.Dispatch(plugClose, 0, 0, nil, 0.0)
 	callbacks.Lock()
-	delete(callbacks.mapping, unsafe.Pointer(p))
+	delete(callbacks.mapping, unsafe.Pointer(p.p))
 	callbacks.Unlock()
+	if p.release != nil {
+		p.release()
+		p.release = nil
+	}
 }
 
 // Resume the plugin processing. It must be called before processing is
@@ -209,6 +223,12 @@ func (p *Plugin) SetSampleRate(sampleRate signal.Frequency) {
 	p.Dispatch(plugSetSampleRate, 0, 0, nil, float32(sampleRate))
 }
 
+// SetProcessPrecision sets precision of the upcoming processing. It must
+// be called before plugin is resumed.
+func (p *Plugin) SetProcessPrecision(precision ProcessPrecision) {
+	p.Dispatch(PlugSetProcessPrecision, 0, int64(precision), nil, 0)
+}
+
 // SetSpeakerArrangement creates and passes SpeakerArrangement structures to plugin
 func (p *Plugin) SetSpeakerArrangement(in, out *SpeakerArrangement) {
 	p.Dispatch(plugSetSpeakerArrangement, 0, int64(uintptr(unsafe.Pointer(in))), unsafe.Pointer(out), 0)
@@ -278,6 +298,28 @@ func (p *Plugin) ParamProperties(index int) (*ParameterProperties, bool) {
 	return nil, false
 }
 
+// InputProperties returns properties of input pin: label, stereo pairing
+// and speaker arrangement.
+func (p *Plugin) InputProperties(index int) (*PinProperties, bool) {
+	var props PinProperties
+	r := p.Dispatch(PlugGetInputProperties, int32(index), 0, unsafe.Pointer(&props), 0)
+	if r > 0 {
+		return &props, true
+	}
+	return nil, false
+}
+
+// OutputProperties returns properties of output pin: label, stereo
+// pairing and speaker arrangement.
+func (p *Plugin) OutputProperties(index int) (*PinProperties, bool) {
+	var props PinProperties
+	r := p.Dispatch(PlugGetOutputProperties, int32(index), 0, unsafe.Pointer(&props), 0)
+	if r > 0 {
+		return &props, true
+	}
+	return nil, false
+}
+
 // GetProgramData returns current preset data. Plugin allocates required
 // memory, then this function allocates new byte slice of required length
 // where data is copied.
@@ -0,0 +1,23 @@
+// +build !plugin
+
+package vst2
+
+import "testing"
+
+func TestHostCallbackLoading(t *testing.T) {
+	callbacks.Lock()
+	callbacks.loading = Host{
+		GetCurrentID: func() [4]byte {
+			return [4]byte{'t', 'e', 's', 't'}
+		},
+	}.Callback()
+	callbacks.Unlock()
+	defer func() {
+		callbacks.Lock()
+		callbacks.loading = nil
+		callbacks.Unlock()
+	}()
+	id := hostCallbackBridge(nil, int32(HostCurrentID), 0, 0, nil, 0)
+	assertEqual(t, "current id", id, int64(uniqueID([4]byte{'t', 'e', 's', 't'})))
+	assertEqual(t, "version", hostCallbackBridge(nil, int32(HostVersion), 0, 0, nil, 0), int64(version))
+}
@@ -16,6 +16,11 @@ func hostCallbackBridge(p *C.CPlugin, opcode int32, index int32, value int64, pt
 	}
 	callbacks.RLock()
 	c, ok := callbacks.mapping[unsafe.Pointer(p)]
+	if !ok && callbacks.loading != nil {
+		// plugin calls host while it's being loaded, e.g. shell requests
+		// HostCurrentID.
+		c, ok = callbacks.loading, true
+	}
 	callbacks.RUnlock()
 	if !ok {
 		panic("plugin was closed")
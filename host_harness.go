@@ -0,0 +1,18 @@
+// +build !plugin,harness
+
+package vst2
+
+//#include "include/harness/gain.c"
+import "C"
+
+// GainVST returns gain plugin that is implemented in C. It's only built
+// with harness tag and allows to test host components without plugin
+// binaries. Plugin has two channels and two parameters: the first one is
+// linear gain and the second one is latency in frames. Returned VST
+// isn't loaded from file, so it must not be closed.
+func GainVST() *VST {
+	return &VST{
+		main: pluginMain(C.gainEntryPoint()),
+		Name: "Gain",
+	}
+}
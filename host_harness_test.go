@@ -0,0 +1,93 @@
+// +build !plugin,harness
+
+package vst2_test
+
+import (
+	"context"
+	"testing"
+
+	"pipelined.dev/audio/vst2"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+func TestChainProcess(t *testing.T) {
+	const bufferSize = 8
+	v := vst2.GainVST()
+	gain := func(value float32, latency int) *vst2.Plugin {
+		p := v.Plugin(vst2.Host{}.Callback())
+		p.SetParamValue(0, value)
+		p.SetParamValue(1, float32(latency))
+		return p
+	}
+	p1 := gain(0.5, 3)
+	defer p1.Close()
+	p2 := gain(0.25, 0)
+	defer p2.Close()
+	c := vst2.NewChain(p1, p2)
+
+	data := [][]float64{make([]float64, 20), make([]float64, 20)}
+	for i := range data[0] {
+		data[0][i] = float64(i + 1)
+		data[1][i] = -float64(i + 1)
+	}
+	scaled := func(gain float64, frames int) [][]float64 {
+		result := [][]float64{make([]float64, frames), make([]float64, frames)}
+		for c := range result {
+			for i := range result[c] {
+				result[c][i] = data[c][i] * gain
+			}
+		}
+		return result
+	}
+	// run processes data in blocks of provided length and returns output.
+	run := func(t *testing.T, blocks ...int) [][]float64 {
+		t.Helper()
+		proc, err := c.Allocator(nil)(mutable.Context{}, bufferSize, pipe.SignalProperties{
+			Channels:   2,
+			SampleRate: 44100,
+		})
+		assertEqual(t, "allocator error", err, nil)
+		assertEqual(t, "start error", proc.StartFunc(context.Background()), nil)
+		result := [][]float64{{}, {}}
+		var pos int
+		for _, frames := range blocks {
+			in := signal.Allocator{Channels: 2, Length: frames, Capacity: frames}.Float64()
+			out := signal.Allocator{Channels: 2, Length: bufferSize, Capacity: bufferSize}.Float64()
+			signal.WriteStripedFloat64([][]float64{data[0][pos : pos+frames], data[1][pos : pos+frames]}, in)
+			pos += frames
+			n, err := proc.ProcessFunc(in, out)
+			assertEqual(t, "process error", err, nil)
+			block := [][]float64{make([]float64, n), make([]float64, n)}
+			signal.ReadStripedFloat64(out.Slice(0, n), block)
+			for c := range result {
+				result[c] = append(result[c], block[c]...)
+			}
+		}
+		assertEqual(t, "flush error", proc.FlushFunc(context.Background()), nil)
+		return result
+	}
+	t.Run("process", func(t *testing.T) {
+		assertEqual(t, "output", run(t, 8, 8, 4), scaled(0.125, 20))
+		assertEqual(t, "latency", c.Latency(), 3)
+	})
+	t.Run("short input", func(t *testing.T) {
+		assertEqual(t, "output", run(t, 2), scaled(0.125, 2))
+	})
+	t.Run("full last block", func(t *testing.T) {
+		// tail is lost if input ends with full block.
+		assertEqual(t, "output", run(t, 8, 8), scaled(0.125, 13))
+	})
+	t.Run("reorder", func(t *testing.T) {
+		c.Move(1, 0)
+		assertEqual(t, "plugins", c.Plugins(), []*vst2.Plugin{p2, p1})
+		assertEqual(t, "output", run(t, 8, 8, 4), scaled(0.125, 20))
+	})
+	t.Run("bypass", func(t *testing.T) {
+		c.SetBypass(0, true)
+		assertEqual(t, "output", run(t, 8, 8, 4), scaled(0.5, 20))
+		c.SetBypass(1, true)
+		assertEqual(t, "bypassed", run(t, 8, 8, 4), data)
+	})
+}
@@ -3,10 +3,18 @@
 package vst2_test
 
 import (
+	"context"
+	"io"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"unsafe"
 
 	"pipelined.dev/audio/vst2"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
 )
 
 func TestPluginParameters(t *testing.T) {
@@ -69,3 +77,182 @@ func TestPluginParameters(t *testing.T) {
 		assertEqual(t, "resonance before", p.ParamValue(4), float32(1))
 	}))
 }
+
+func TestHostProcessEvents(t *testing.T) {
+	var received []vst2.Event
+	callback := vst2.Host{
+		ProcessEvents: func(events ...vst2.Event) bool {
+			received = events
+			return true
+		},
+	}.Callback()
+
+	events := vst2.Events(
+		&vst2.MIDIEvent{Data: [3]byte{0x90, 60, 100}},
+		&vst2.MIDIEvent{Data: [3]byte{0x80, 60, 0}},
+	)
+	defer events.Free()
+
+	result := callback(vst2.HostProcessEvents, 0, 0, unsafe.Pointer(events), 0)
+	assertEqual(t, "result", result, int64(1))
+	assertEqual(t, "num events", len(received), 2)
+	assertEqual(t, "note on", received[0].(*vst2.MIDIEvent).Data, [3]byte{0x90, 60, 100})
+	assertEqual(t, "note off", received[1].(*vst2.MIDIEvent).Data, [3]byte{0x80, 60, 0})
+}
+
+func TestHostAutomate(t *testing.T) {
+	var (
+		index int
+		value float32
+	)
+	callback := vst2.Host{
+		Automate: func(i int, v float32) {
+			index, value = i, v
+		},
+	}.Callback()
+
+	callback(vst2.HostAutomate, 3, 0, nil, 0.25)
+	assertEqual(t, "index", index, 3)
+	assertEqual(t, "value", value, float32(0.25))
+}
+
+func TestMultiProcessor(t *testing.T) {
+	v, err := vst2.Open(pluginPath())
+	assertEqual(t, "vst error", err, nil)
+	defer v.Close()
+
+	var (
+		mu        sync.Mutex
+		automated []int
+	)
+	p := v.MultiProcessor(vst2.Host{
+		Automate: func(index int, value float32) {
+			mu.Lock()
+			defer mu.Unlock()
+			automated = append(automated, index)
+		},
+	}, nil)
+	defer p.Close()
+	proc, err := p.Allocator(nil)(mutable.Context{}, 32, pipe.SignalProperties{
+		Channels:   2,
+		SampleRate: 44100,
+	})
+	assertEqual(t, "allocator error", err, nil)
+	assertEqual(t, "instances", len(p.Plugins()), 2)
+	assertEqual(t, "channels", proc.SignalProperties.Channels, 2)
+
+	assertParam := func(t *testing.T, index int, value float32) {
+		t.Helper()
+		for _, plugin := range p.Plugins() {
+			assertEqual(t, "param value", plugin.ParamValue(index), value)
+		}
+	}
+	t.Run("set param", func(t *testing.T) {
+		p.SetParamValue(4, 0.5)
+		assertParam(t, 4, 0.5)
+	})
+	t.Run("automate", func(t *testing.T) {
+		automated = nil
+		p.Plugins()[0].SetParamValue(4, 0.25)
+		vst2.InstanceCallback(p, 0)(vst2.HostAutomate, 4, 0, nil, 0.25)
+		assertParam(t, 4, 0.25)
+		assertEqual(t, "automated", automated, []int{4})
+	})
+	t.Run("concurrent automate", func(t *testing.T) {
+		automated = nil
+		p.Plugins()[0].SetParamValue(4, 0.75)
+		p.Plugins()[1].SetParamValue(5, 0.75)
+		var wg sync.WaitGroup
+		wg.Add(2)
+		for i := range p.Plugins() {
+			go func(i int) {
+				defer wg.Done()
+				vst2.InstanceCallback(p, i)(vst2.HostAutomate, int32(4+i), 0, nil, 0.75)
+			}(i)
+		}
+		wg.Wait()
+		assertParam(t, 4, 0.75)
+		assertParam(t, 5, 0.75)
+		sort.Ints(automated)
+		assertEqual(t, "automated", automated, []int{4, 5})
+	})
+	t.Run("programs", func(t *testing.T) {
+		p.SetProgram(1)
+		for _, plugin := range p.Plugins() {
+			assertEqual(t, "set program", plugin.Program(), 1)
+		}
+		p.Plugins()[1].SetProgram(2)
+		vst2.InstanceCallback(p, 1)(vst2.HostUpdateDisplay, 0, 0, nil, 0)
+		for _, plugin := range p.Plugins() {
+			assertEqual(t, "linked program", plugin.Program(), 2)
+		}
+	})
+
+	in := signal.Allocator{Channels: 2, Length: 32, Capacity: 32}.Float64()
+	out := signal.Allocator{Channels: 2, Length: 32, Capacity: 32}.Float64()
+	assertEqual(t, "start error", proc.StartFunc(context.Background()), nil)
+	process := func(parallel bool) func(*testing.T) {
+		return func(t *testing.T) {
+			p.Parallel = parallel
+			n, err := proc.ProcessFunc(in, out)
+			assertEqual(t, "process error", err, nil)
+			assertEqual(t, "processed", n, in.Length())
+			short := signal.Allocator{Channels: 2, Length: 16, Capacity: 16}.Float64()
+			n, err = proc.ProcessFunc(short, out)
+			assertEqual(t, "short error", err, nil)
+			assertEqual(t, "short processed", n, short.Length())
+		}
+	}
+	t.Run("serial", process(false))
+	t.Run("parallel", process(true))
+	t.Run("sync program", func(t *testing.T) {
+		// program is changed without display update.
+		p.Plugins()[0].SetProgram(3)
+		_, err := proc.ProcessFunc(in, out)
+		assertEqual(t, "process error", err, nil)
+		for _, plugin := range p.Plugins() {
+			assertEqual(t, "synced program", plugin.Program(), 3)
+		}
+	})
+	assertEqual(t, "flush error", proc.FlushFunc(context.Background()), nil)
+}
+
+func TestSplitterProcess(t *testing.T) {
+	v, err := vst2.Open(pluginPath())
+	assertEqual(t, "vst error", err, nil)
+	defer v.Close()
+
+	p := v.Plugin(vst2.Host{}.Callback())
+	defer p.Close()
+	s := vst2.NewSplitter(p)
+	assertEqual(t, "groups before allocation", len(s.Groups()), 0)
+	sink, err := s.Sink(nil)(mutable.Context{}, 32, pipe.SignalProperties{
+		Channels:   2,
+		SampleRate: 44100,
+	})
+	assertEqual(t, "sink error", err, nil)
+	var grouped int
+	for _, g := range s.Groups() {
+		grouped += len(g.Channels)
+	}
+	assertEqual(t, "grouped outputs", grouped, p.NumOutputs())
+
+	source, err := s.Source(0)(mutable.Context{}, 32)
+	assertEqual(t, "source error", err, nil)
+	channels := len(s.Groups()[0].Channels)
+	assertEqual(t, "source channels", source.SignalProperties.Channels, channels)
+	assertEqual(t, "source sample rate", source.SignalProperties.SampleRate, signal.Frequency(44100))
+
+	assertEqual(t, "start error", sink.StartFunc(context.Background()), nil)
+	in := signal.Allocator{Channels: 2, Length: 32, Capacity: 32}.Float64()
+	assertEqual(t, "sink func error", sink.SinkFunc(in), nil)
+	assertEqual(t, "flush error", sink.FlushFunc(context.Background()), nil)
+
+	out := signal.Allocator{Channels: channels, Length: 32, Capacity: 32}.Float64()
+	n, err := source.SourceFunc(out)
+	assertEqual(t, "source func error", err, nil)
+	assertEqual(t, "read", n, 32)
+	_, err = source.SourceFunc(out)
+	assertEqual(t, "finished", err, io.EOF)
+	assertEqual(t, "source flush error", source.FlushFunc(context.Background()), nil)
+}
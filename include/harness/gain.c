@@ -0,0 +1,137 @@
+#include <stdlib.h>
+#include "include/vst.h"
+
+// Gain plugin is implemented in C, so host components can be tested
+// without plugin binaries. It has two channels and two parameters: linear
+// gain and latency in frames. Signal is multiplied by gain and delayed by
+// latency. Functions are static, so they're not exported.
+
+#define GAIN_CHANNELS 2
+#define GAIN_CLOSE 1
+#define GAIN_STATE_CHANGED 12
+
+typedef struct GainState {
+	float gain;
+	int32_t latency;
+	// delay line of every channel and its position.
+	double *line[GAIN_CHANNELS];
+	int32_t pos;
+} GainState;
+
+static void setGainLatency(GainState *s, int32_t latency) {
+	for (int c = 0; c < GAIN_CHANNELS; c++) {
+		free(s->line[c]);
+		s->line[c] = latency > 0 ? calloc(latency, sizeof(double)) : NULL;
+	}
+	s->latency = latency;
+	s->pos = 0;
+}
+
+// gainSample applies gain to the sample and pushes it into delay line.
+// Returns delayed sample.
+static double gainSample(GainState *s, int c, int32_t pos, double in) {
+	double v = in * s->gain;
+	if (s->latency == 0) {
+		return v;
+	}
+	double out = s->line[c][pos];
+	s->line[c][pos] = v;
+	return out;
+}
+
+static int64_t dispatchGain(CPlugin *plugin, int32_t opcode, int32_t index, int64_t value, void *ptr, float opt) {
+	GainState *s = plugin->object;
+	switch (opcode) {
+	case GAIN_STATE_CHANGED:
+		// delay line is cleared when plugin is resumed.
+		if (value == 1) {
+			setGainLatency(s, s->latency);
+		}
+		break;
+	case GAIN_CLOSE:
+		setGainLatency(s, 0);
+		free(s);
+		free(plugin);
+		break;
+	}
+	return 0;
+}
+
+static void processDoubleGain(CPlugin *plugin, double **inputs, double **outputs, int32_t sampleFrames) {
+	GainState *s = plugin->object;
+	for (int c = 0; c < GAIN_CHANNELS; c++) {
+		for (int32_t i = 0, pos = s->pos; i < sampleFrames; i++) {
+			outputs[c][i] = gainSample(s, c, pos, inputs[c][i]);
+			if (s->latency > 0 && ++pos == s->latency) {
+				pos = 0;
+			}
+		}
+	}
+	if (s->latency > 0) {
+		s->pos = (s->pos + sampleFrames) % s->latency;
+	}
+}
+
+static void processFloatGain(CPlugin *plugin, float **inputs, float **outputs, int32_t sampleFrames) {
+	GainState *s = plugin->object;
+	for (int c = 0; c < GAIN_CHANNELS; c++) {
+		for (int32_t i = 0, pos = s->pos; i < sampleFrames; i++) {
+			outputs[c][i] = (float)gainSample(s, c, pos, inputs[c][i]);
+			if (s->latency > 0 && ++pos == s->latency) {
+				pos = 0;
+			}
+		}
+	}
+	if (s->latency > 0) {
+		s->pos = (s->pos + sampleFrames) % s->latency;
+	}
+}
+
+static void setParameterGain(CPlugin *plugin, int32_t index, float value) {
+	GainState *s = plugin->object;
+	switch (index) {
+	case 0:
+		s->gain = value;
+		break;
+	case 1:
+		setGainLatency(s, (int32_t)value);
+		plugin->initialDelay = s->latency;
+		break;
+	}
+}
+
+static float getParameterGain(CPlugin *plugin, int32_t index) {
+	GainState *s = plugin->object;
+	switch (index) {
+	case 0:
+		return s->gain;
+	case 1:
+		return (float)s->latency;
+	}
+	return 0;
+}
+
+// Entry point of gain plugin.
+static CPlugin* gainPluginMain(HostCallback host) {
+	CPlugin *p = calloc(1, sizeof(CPlugin));
+	GainState *s = calloc(1, sizeof(GainState));
+	s->gain = 1;
+	p->magic = 'V'<<24 | 's'<<16 | 't'<<8 | 'P';
+	p->object = s;
+	p->numParams = 2;
+	p->numInputs = GAIN_CHANNELS;
+	p->numOutputs = GAIN_CHANNELS;
+	// replacing and double replacing processing.
+	p->flags = 1<<4 | 1<<12;
+	p->dispatcher = dispatchGain;
+	p->setParameter = setParameterGain;
+	p->getParameter = getParameterGain;
+	p->processDouble = processDoubleGain;
+	p->processFloat = processFloatGain;
+	return p;
+}
+
+// Returns entry point of gain plugin.
+static EntryPoint gainEntryPoint() {
+	return gainPluginMain;
+}
@@ -0,0 +1,56 @@
+#include <stdlib.h>
+#include "include/vst.h"
+
+// Harness functions are static, so they're not exported from plugin
+// binaries.
+
+//Go harness callback prototype
+int64_t harnessCallbackBridge(CPlugin *plugin, int32_t opcode, int32_t index, int64_t value, void *ptr, float opt);
+
+//Go plugin bridges prototypes
+int64_t dispatchPluginBridge(CPlugin *plugin, int32_t opcode, int32_t index, int64_t value, void *ptr, float opt);
+void processDoublePluginBridge(CPlugin *plugin, double ** inputs, double ** outputs, int32_t sampleFrames);
+void processFloatPluginBridge(CPlugin *plugin, float **inputs, float **outputs, int32_t sampleFrames);
+float getParameterPluginBridge(CPlugin *plugin, int32_t paramIndex);
+void setParameterPluginBridge(CPlugin *plugin, int32_t paramIndex, float value);
+
+// Allocates plugin structure with Go bridges the same way as VSTPluginMain.
+static CPlugin* newHarnessPlugin() {
+	CPlugin *p = calloc(1,sizeof(CPlugin));
+	p->dispatcher = dispatchPluginBridge;
+	p->getParameter = getParameterPluginBridge;
+	p->setParameter = setParameterPluginBridge;
+	p->processDouble = processDoublePluginBridge;
+	p->processFloat = processFloatPluginBridge;
+	return p;
+}
+
+// Returns host callback that is handled by harness.
+static HostCallback harnessCallback() {
+	return (HostCallback)harnessCallbackBridge;
+}
+
+// Bridge to call dispatch function of plugin
+static int64_t dispatchHarness(CPlugin *plugin, int32_t opcode, int32_t index, int64_t value, void *ptr, float opt){
+	return plugin->dispatcher(plugin, opcode, index, value, ptr, opt);
+}
+
+// Bridge to call process double function of plugin
+static void processDoubleHarness(CPlugin *plugin, double ** inputs, double ** outputs, int32_t sampleFrames){
+	plugin->processDouble(plugin, inputs, outputs, sampleFrames);
+}
+
+// Bridge to call process float function of plugin
+static void processFloatHarness(CPlugin *plugin, float **inputs, float **outputs, int32_t sampleFrames){
+	plugin->processFloat(plugin, inputs, outputs, sampleFrames);
+}
+
+// Bridge to call get parameter function of plugin
+static float getParameterHarness(CPlugin *plugin, int32_t paramIndex) {
+	return plugin->getParameter(plugin, paramIndex);
+}
+
+// Bridge to call set parameter function of plugin
+static void setParameterHarness(CPlugin *plugin, int32_t paramIndex, float value) {
+	plugin->setParameter(plugin, paramIndex, value);
+}
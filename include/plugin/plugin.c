@@ -7,7 +7,7 @@
 #define VSTAPI __attribute__((visibility("default")))
 #endif
 
-void newGoPlugin(CPlugin *plugin, HostCallback c);
+int32_t newGoPlugin(CPlugin *plugin, HostCallback c);
 
 //Go dispatch prototype
 int64_t dispatchPluginBridge(CPlugin *plugin, int32_t opcode, int32_t index, int64_t value, void *ptr, float opt);
@@ -31,7 +31,10 @@ VSTAPI CPlugin* VSTPluginMain(HostCallback c) {
     p->setParameter = setParameterPluginBridge;
     p->processDouble = processDoublePluginBridge;
     p->processFloat = processFloatPluginBridge;
-    newGoPlugin(p, c);
+    if (newGoPlugin(p, c) == 0) {
+        free(p);
+        return NULL;
+    }
     return p;
 }
 
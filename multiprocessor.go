@@ -0,0 +1,372 @@
+// +build !plugin
+
+package vst2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+// MultiProcessor is pipe component that processes every channel of the
+// signal with separate instance of mono plugin. It allows to apply mono
+// plugins to stereo or surround signal. Parameters and programs of
+// instances are linked: MultiProcessor methods change all instances and
+// parameter and program changes made in the editor of any instance are
+// applied to other instances. Program change is propagated when instance
+// notifies host with UpdateDisplay callback. Plugins that don't do it
+// are synced at the start of every processed block.
+type MultiProcessor struct {
+	vst        *VST
+	host       Host
+	progressFn ProgressProcessedFunc
+	bufferSize int
+	sampleRate signal.Frequency
+	// mu guards plugins and linking slices, because instance callbacks
+	// read them while instances are created and closed.
+	mu      sync.RWMutex
+	plugins []*Plugin
+	// linking counts changes that are being applied to every instance.
+	// Instance doesn't propagate its notifications while its counter is
+	// not zero, so changes made by other instances are not echoed.
+	// Counters are allocated separately, so they are not moved when
+	// instances are added.
+	linking []*int32
+	// program is the current program of all instances. programMu
+	// serializes program changes, so concurrent changes don't revert
+	// each other.
+	programMu sync.Mutex
+	program   int
+	// Parallel makes instances to process signal concurrently.
+	Parallel bool
+	// BufferPool is optional, if set then processing buffers are taken
+	// from it and released into it when processor is flushed.
+	BufferPool *BufferPool
+}
+
+// MultiProcessor returns processor that creates instance of the plugin
+// for every channel of the signal. Instances are created when processor
+// is allocated. Processor always overrides GetBufferSize and
+// GetSampleRate callbacks, because this values are injected when
+// processor is allocated by pipe.
+func (v *VST) MultiProcessor(h Host, progressFn ProgressProcessedFunc) *MultiProcessor {
+	p := MultiProcessor{
+		vst:        v,
+		progressFn: progressFn,
+	}
+	h.GetBufferSize = func() int {
+		return p.bufferSize
+	}
+	h.GetSampleRate = func() signal.Frequency {
+		return p.sampleRate
+	}
+	p.host = h
+	return &p
+}
+
+// Plugins returns plugin instances, one for every channel.
+func (p *MultiProcessor) Plugins() []*Plugin {
+	plugins, _ := p.instances()
+	return plugins
+}
+
+// SetParamValue sets new value for parameter of all instances.
+func (p *MultiProcessor) SetParamValue(index int, value float32) {
+	plugins, linking := p.instances()
+	for i := range plugins {
+		link(plugins[i], linking[i], func(plugin *Plugin) {
+			plugin.SetParamValue(index, value)
+		})
+	}
+}
+
+// SetProgram changes current program index of all instances.
+func (p *MultiProcessor) SetProgram(index int) {
+	p.programMu.Lock()
+	defer p.programMu.Unlock()
+	p.program = index
+	plugins, linking := p.instances()
+	for i := range plugins {
+		link(plugins[i], linking[i], func(plugin *Plugin) {
+			plugin.SetProgram(index)
+		})
+	}
+}
+
+// Close closes all instances.
+func (p *MultiProcessor) Close() {
+	p.mu.Lock()
+	plugins := p.plugins
+	p.plugins, p.linking = nil, nil
+	p.mu.Unlock()
+	for _, plugin := range plugins {
+		plugin.Close()
+	}
+}
+
+// instances returns copies of plugins and linking slices.
+func (p *MultiProcessor) instances() ([]*Plugin, []*int32) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append([]*Plugin(nil), p.plugins...), append([]*int32(nil), p.linking...)
+}
+
+// Allocator returns pipe processor allocator that can be plugged into
+// line. Init function is applied to every instance.
+func (p *MultiProcessor) Allocator(init ProcessorInitFunc) pipe.ProcessorAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Processor, error) {
+		p.bufferSize = bufferSize
+		p.sampleRate = props.SampleRate
+		if err := p.instantiate(props.Channels); err != nil {
+			return pipe.Processor{}, err
+		}
+		plugins, linking := p.instances()
+		precision := ProcessDouble
+		for _, plugin := range plugins {
+			if plugin.NumInputs() < 1 || plugin.NumOutputs() < 1 {
+				return pipe.Processor{}, errors.New("plugin must have at least one input and output")
+			}
+			if !plugin.CanProcessFloat64() {
+				precision = ProcessFloat
+			}
+		}
+		for _, plugin := range plugins {
+			plugin.Start()
+			plugin.SetSampleRate(props.SampleRate)
+			plugin.SetBufferSize(bufferSize)
+			plugin.SetProcessPrecision(precision)
+			if init != nil {
+				init(plugin)
+			}
+		}
+		var processFn pipe.ProcessFunc
+		var flushFn pipe.FlushFunc
+		if precision == ProcessDouble {
+			processFn, flushFn = p.doubleFns(plugins, linking)
+		} else {
+			processFn, flushFn = p.floatFns(plugins, linking)
+		}
+		return pipe.Processor{
+			SignalProperties: props,
+			StartFunc: func(context.Context) error {
+				for _, plugin := range plugins {
+					plugin.Resume()
+				}
+				return nil
+			},
+			ProcessFunc: processFn,
+			FlushFunc:   flushFn,
+		}, nil
+	}
+}
+
+// instantiate creates or closes instances to match number of channels.
+// Instances are created and closed without holding the lock, because
+// they may call host at this moment.
+func (p *MultiProcessor) instantiate(channels int) error {
+	for {
+		p.mu.Lock()
+		if len(p.plugins) <= channels {
+			p.mu.Unlock()
+			break
+		}
+		last := p.plugins[len(p.plugins)-1]
+		p.plugins = p.plugins[:len(p.plugins)-1]
+		p.linking = p.linking[:len(p.plugins)]
+		p.mu.Unlock()
+		last.Close()
+	}
+	for i := len(p.Plugins()); i < channels; i++ {
+		plugin := p.vst.Plugin(p.instanceHost(i).Callback())
+		if plugin == nil {
+			return fmt.Errorf("failed to create instance for channel %d", i)
+		}
+		p.mu.Lock()
+		p.plugins = append(p.plugins, plugin)
+		p.linking = append(p.linking, new(int32))
+		p.mu.Unlock()
+	}
+	return nil
+}
+
+// link applies change to the instance. Notifications that instance sends
+// during the change are not propagated.
+func link(plugin *Plugin, linking *int32, fn func(*Plugin)) {
+	atomic.AddInt32(linking, 1)
+	defer atomic.AddInt32(linking, -1)
+	fn(plugin)
+}
+
+// linked returns true if change is being applied to the instance.
+func linked(linking *int32) bool {
+	return atomic.LoadInt32(linking) > 0
+}
+
+// instanceHost returns host for instance that propagates automated
+// parameter values and program changes to other instances.
+func (p *MultiProcessor) instanceHost(instance int) Host {
+	h := p.host
+	h.Automate = func(index int, value float32) {
+		plugins, linking := p.instances()
+		// ignore changes caused by propagation.
+		if instance >= len(plugins) || linked(linking[instance]) {
+			return
+		}
+		for i := range plugins {
+			if i == instance {
+				continue
+			}
+			link(plugins[i], linking[i], func(plugin *Plugin) {
+				plugin.SetParamValue(index, value)
+			})
+		}
+		if p.host.Automate != nil {
+			p.host.Automate(index, value)
+		}
+	}
+	// plugin notifies about program change with display update.
+	h.UpdateDisplay = func() bool {
+		plugins, linking := p.instances()
+		if instance < len(plugins) && !linked(linking[instance]) {
+			p.programMu.Lock()
+			p.propagateProgram(plugins, linking, instance)
+			p.programMu.Unlock()
+		}
+		if p.host.UpdateDisplay != nil {
+			return p.host.UpdateDisplay()
+		}
+		return false
+	}
+	return h
+}
+
+// propagateProgram applies program of the instance to other instances if
+// it was changed. It must be called with programMu locked.
+func (p *MultiProcessor) propagateProgram(plugins []*Plugin, linking []*int32, instance int) {
+	program := plugins[instance].Program()
+	if p.program == program {
+		return
+	}
+	p.program = program
+	for i := range plugins {
+		if i == instance {
+			continue
+		}
+		link(plugins[i], linking[i], func(plugin *Plugin) {
+			plugin.SetProgram(program)
+		})
+	}
+}
+
+// syncPrograms propagates program of the first instance that changed it
+// without notifying host. It's called at the start of every block.
+func (p *MultiProcessor) syncPrograms(plugins []*Plugin, linking []*int32) {
+	p.programMu.Lock()
+	defer p.programMu.Unlock()
+	for i := range plugins {
+		if !linked(linking[i]) && plugins[i].Program() != p.program {
+			p.propagateProgram(plugins, linking, i)
+			return
+		}
+	}
+}
+
+// forEach calls provided function for every instance, concurrently if
+// processor is parallel.
+func (p *MultiProcessor) forEach(instances int, fn func(i int)) {
+	if !p.Parallel {
+		for i := 0; i < instances; i++ {
+			fn(i)
+		}
+		return
+	}
+	var wg sync.WaitGroup
+	wg.Add(instances)
+	for i := 0; i < instances; i++ {
+		go func(i int) {
+			fn(i)
+			wg.Done()
+		}(i)
+	}
+	wg.Wait()
+}
+
+func (p *MultiProcessor) doubleFns(plugins []*Plugin, linking []*int32) (pipe.ProcessFunc, pipe.FlushFunc) {
+	channels := len(plugins)
+	pool := p.BufferPool
+	stageIn := pool.GetDouble(channels, p.bufferSize)
+	stageOut := pool.GetDouble(channels, p.bufferSize)
+	ins := make([]DoubleBuffer, channels)
+	outs := make([]DoubleBuffer, channels)
+	for i, plugin := range plugins {
+		ins[i] = pool.GetDouble(plugin.NumInputs(), p.bufferSize)
+		outs[i] = pool.GetDouble(plugin.NumOutputs(), p.bufferSize)
+	}
+	return func(in, out signal.Floating) (int, error) {
+			p.syncPrograms(plugins, linking)
+			stageIn.Write(in)
+			p.forEach(channels, func(i int) {
+				copy(ins[i].Channel(0), stageIn.Channel(i))
+				plugins[i].ProcessDouble(ins[i], outs[i])
+				copy(stageOut.Channel(i), outs[i].Channel(0))
+			})
+			stageOut.Read(out)
+			if p.progressFn != nil {
+				p.progressFn(in.Length())
+			}
+			return in.Length(), nil
+		},
+		func(context.Context) error {
+			for i, plugin := range plugins {
+				plugin.Suspend()
+				pool.PutDouble(ins[i])
+				pool.PutDouble(outs[i])
+			}
+			pool.PutDouble(stageIn)
+			pool.PutDouble(stageOut)
+			return nil
+		}
+}
+
+func (p *MultiProcessor) floatFns(plugins []*Plugin, linking []*int32) (pipe.ProcessFunc, pipe.FlushFunc) {
+	channels := len(plugins)
+	pool := p.BufferPool
+	stageIn := pool.GetFloat(channels, p.bufferSize)
+	stageOut := pool.GetFloat(channels, p.bufferSize)
+	ins := make([]FloatBuffer, channels)
+	outs := make([]FloatBuffer, channels)
+	for i, plugin := range plugins {
+		ins[i] = pool.GetFloat(plugin.NumInputs(), p.bufferSize)
+		outs[i] = pool.GetFloat(plugin.NumOutputs(), p.bufferSize)
+	}
+	return func(in, out signal.Floating) (int, error) {
+			p.syncPrograms(plugins, linking)
+			stageIn.Write(in)
+			p.forEach(channels, func(i int) {
+				copy(ins[i].Channel(0), stageIn.Channel(i))
+				plugins[i].ProcessFloat(ins[i], outs[i])
+				copy(stageOut.Channel(i), outs[i].Channel(0))
+			})
+			stageOut.Read(out)
+			if p.progressFn != nil {
+				p.progressFn(in.Length())
+			}
+			return in.Length(), nil
+		},
+		func(context.Context) error {
+			for i, plugin := range plugins {
+				plugin.Suspend()
+				pool.PutFloat(ins[i])
+				pool.PutFloat(outs[i])
+			}
+			pool.PutFloat(stageIn)
+			pool.PutFloat(stageOut)
+			return nil
+		}
+}
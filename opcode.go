@@ -369,7 +369,10 @@ const (
 
 	// deprecated in VST v2.4
 	hostSetTime
-	// deprecated in VST v2.4
+	// hostTempoAt passed when plugin needs tempo at position. Deprecated
+	// in VST v2.4.
+	// Value: position in samples.
+	// Return: tempo in BPM * 10000.
 	hostTempoAt
 	// deprecated in VST v2.4
 	hostGetNumAutomatableParameters
@@ -0,0 +1,243 @@
+package vst2
+
+import "sync"
+
+// parameterQueueSize is a number of parameter changes that queue keeps
+// between process calls.
+const parameterQueueSize = 256
+
+// parameterProperties returns properties of parameter with provided index.
+// Categories are numbered in order of their first appearance, starting
+// with 1.
+func parameterProperties(params []*Parameter, index int) ParameterProperties {
+	p := params[index]
+	props := ParameterProperties{
+		StepFloat:        p.StepFloat,
+		SmallStepFloat:   p.SmallStepFloat,
+		LargeStepFloat:   p.LargeStepFloat,
+		MinInteger:       int32(p.MinInteger),
+		MaxInteger:       int32(p.MaxInteger),
+		StepInteger:      int32(p.StepInteger),
+		LargeStepInteger: int32(p.LargeStepInteger),
+	}
+	copyASCII(props.Label[:], p.Name)
+	copyASCII(props.ShortLabel[:], p.ShortLabel)
+	if p.IsSwitch {
+		props.Flags |= ParameterIsSwitch
+	}
+	if p.CanRamp {
+		props.Flags |= ParameterCanRamp
+	}
+	if p.MinInteger != p.MaxInteger {
+		props.Flags |= ParameterUsesIntegerMinMax
+	}
+	if p.StepFloat != 0 || p.SmallStepFloat != 0 || p.LargeStepFloat != 0 {
+		props.Flags |= ParameterUsesFloatStep
+	}
+	if p.StepInteger != 0 || p.LargeStepInteger != 0 {
+		props.Flags |= ParameterUsesIntStep
+	}
+	if p.DisplayIndex > 0 {
+		props.Flags |= ParameterSupportsDisplayIndex
+		props.DisplayIndex = int16(p.DisplayIndex - 1)
+	}
+	if p.Category != nil {
+		props.Flags |= ParameterSupportsDisplayCategory
+		copyASCII(props.CategoryLabel[:], p.Category.Label)
+		categories := make(map[*ParameterCategory]struct{})
+		for i := range params {
+			c := params[i].Category
+			if c == nil {
+				continue
+			}
+			if _, ok := categories[c]; !ok && props.Category == 0 {
+				categories[c] = struct{}{}
+				if c == p.Category {
+					props.Category = int16(len(categories))
+				}
+			}
+			if c == p.Category {
+				props.ParametersInCategory++
+			}
+		}
+	}
+	return props
+}
+
+// ParameterSmoother provides per-block linear ramp of parameter value to
+// avoid zipper noise when parameter changes. It keeps the state between
+// blocks, so it must be used only in processing routine.
+type ParameterSmoother struct {
+	Parameter   *Parameter
+	last        float32
+	initialized bool
+}
+
+// Next returns the ramp for the block of provided size. The ramp starts at
+// the value reached in the end of previous block and ends at the current
+// value of the parameter. Values are mapped with GetValueFunc, when it was
+// set for the Parameter. Usage:
+//
+//	v, step := s.Next(in.Frames)
+//	for i := 0; i < in.Frames; i++ {
+//		out.Channel(0)[i] = in.Channel(0)[i] * float64(v)
+//		v += step
+//	}
+func (s *ParameterSmoother) Next(frames int) (value, step float32) {
+	target := s.Parameter.GetValue()
+	if !s.initialized || frames <= 0 {
+		s.last, s.initialized = target, true
+		return target, 0
+	}
+	value = s.last
+	step = (target - value) / float32(frames)
+	s.last = target
+	return value, step
+}
+
+type (
+	// ParameterChange is a change of parameter value at certain frame of
+	// the processing block.
+	ParameterChange struct {
+		Frame int     // Offset in frames within the processing block.
+		Index int     // Index of parameter.
+		Value float32 // Normalized value.
+	}
+
+	// ParameterQueue records timestamped parameter changes that arrive
+	// between process calls. Changes made by host with setParameter are
+	// applied immediately and recorded at frame 0. Changes made with
+	// mapped MIDI CC events are recorded at their delta frames and
+	// applied when processing reaches them. SplitDouble or SplitFloat
+	// must be called in every process call to consume recorded changes.
+	// Queue keeps up to parameterQueueSize changes, if it's full then the
+	// earliest change is applied immediately and removed from the queue.
+	ParameterQueue struct {
+		// Controllers maps MIDI CC numbers to parameter indexes.
+		Controllers map[uint8]int
+
+		parameters []*Parameter
+		mu         sync.Mutex
+		pending    []ParameterChange
+		current    []ParameterChange
+		// sub-block buffers are reused between calls.
+		doubleIn, doubleOut DoubleBuffer
+		floatIn, floatOut   FloatBuffer
+	}
+)
+
+// record adds parameter change to the queue. Changes are kept sorted by
+// frame.
+func (q *ParameterQueue) record(c ParameterChange) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if cap(q.pending) == 0 {
+		q.pending = make([]ParameterChange, 0, parameterQueueSize)
+	}
+	if len(q.pending) == parameterQueueSize {
+		q.parameters[q.pending[0].Index].SetValue(q.pending[0].Value)
+		q.pending = append(q.pending[:0], q.pending[1:]...)
+	}
+	q.pending = append(q.pending, c)
+	for i := len(q.pending) - 1; i > 0 && q.pending[i-1].Frame > q.pending[i].Frame; i-- {
+		q.pending[i-1], q.pending[i] = q.pending[i], q.pending[i-1]
+	}
+}
+
+// recordEvents records changes from MIDI CC events mapped to parameters.
+func (q *ParameterQueue) recordEvents(e *EventsPtr) {
+	if len(q.Controllers) == 0 {
+		return
+	}
+	for i := 0; i < e.NumEvents(); i++ {
+		ev, ok := e.Event(i).(*MIDIEvent)
+		if !ok || ev.Data[0]&0xF0 != 0xB0 {
+			continue
+		}
+		if index, ok := q.Controllers[ev.Data[1]]; ok && index < len(q.parameters) {
+			q.record(ParameterChange{
+				Frame: int(ev.DeltaFrames),
+				Index: index,
+				Value: float32(ev.Data[2]) / 127,
+			})
+		}
+	}
+}
+
+// Changes returns parameter changes of the last block that was split by
+// SplitDouble or SplitFloat. It's only valid during the process call.
+func (q *ParameterQueue) Changes() []ParameterChange {
+	return q.current
+}
+
+// next moves pending changes into the current block.
+func (q *ParameterQueue) next() []ParameterChange {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.current, q.pending = q.pending, q.current[:0]
+	return q.current
+}
+
+// SplitDouble splits the processing block at frames where parameters
+// change and calls provided function for every sub-block. Parameter values
+// are updated before corresponding sub-block is processed. It should be
+// called in ProcessDoubleFunc.
+func (q *ParameterQueue) SplitDouble(in, out DoubleBuffer, fn func(in, out DoubleBuffer)) {
+	start := 0
+	for _, c := range q.next() {
+		frame := clamp(c.Frame, 0, in.Frames)
+		if frame > start {
+			in.slice(&q.doubleIn, start, frame)
+			out.slice(&q.doubleOut, start, frame)
+			fn(q.doubleIn, q.doubleOut)
+			start = frame
+		}
+		q.parameters[c.Index].SetValue(c.Value)
+	}
+	if start == 0 {
+		fn(in, out)
+		return
+	}
+	if start < in.Frames {
+		in.slice(&q.doubleIn, start, in.Frames)
+		out.slice(&q.doubleOut, start, in.Frames)
+		fn(q.doubleIn, q.doubleOut)
+	}
+}
+
+// SplitFloat splits the processing block at frames where parameters
+// change and calls provided function for every sub-block. Parameter values
+// are updated before corresponding sub-block is processed. It should be
+// called in ProcessFloatFunc.
+func (q *ParameterQueue) SplitFloat(in, out FloatBuffer, fn func(in, out FloatBuffer)) {
+	start := 0
+	for _, c := range q.next() {
+		frame := clamp(c.Frame, 0, in.Frames)
+		if frame > start {
+			in.slice(&q.floatIn, start, frame)
+			out.slice(&q.floatOut, start, frame)
+			fn(q.floatIn, q.floatOut)
+			start = frame
+		}
+		q.parameters[c.Index].SetValue(c.Value)
+	}
+	if start == 0 {
+		fn(in, out)
+		return
+	}
+	if start < in.Frames {
+		in.slice(&q.floatIn, start, in.Frames)
+		out.slice(&q.floatOut, start, in.Frames)
+		fn(q.floatIn, q.floatOut)
+	}
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
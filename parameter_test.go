@@ -0,0 +1,136 @@
+package vst2
+
+import "testing"
+
+func TestParameterProperties(t *testing.T) {
+	var (
+		filter = &ParameterCategory{Label: "Filter"}
+		amp    = &ParameterCategory{Label: "Amp"}
+		params = []*Parameter{
+			{Name: "Cutoff", Category: filter, StepFloat: 0.1, DisplayIndex: 2},
+			{Name: "Gain", Category: amp, MinInteger: -20, MaxInteger: 20, StepInteger: 1},
+			{Name: "Resonance", ShortLabel: "Res", Category: filter},
+			{Name: "Bypass", IsSwitch: true},
+		}
+	)
+	cutoff := parameterProperties(params, 0)
+	assertEqual(t, "cutoff flags", cutoff.Flags, ParameterUsesFloatStep|ParameterSupportsDisplayIndex|ParameterSupportsDisplayCategory)
+	assertEqual(t, "cutoff label", cutoff.Label.String(), "Cutoff")
+	assertEqual(t, "cutoff display index", cutoff.DisplayIndex, int16(1))
+	assertEqual(t, "cutoff category", cutoff.Category, int16(1))
+	assertEqual(t, "cutoff category label", cutoff.CategoryLabel.String(), "Filter")
+	assertEqual(t, "cutoff params in category", cutoff.ParametersInCategory, int16(2))
+
+	gain := parameterProperties(params, 1)
+	assertEqual(t, "gain flags", gain.Flags, ParameterUsesIntegerMinMax|ParameterUsesIntStep|ParameterSupportsDisplayCategory)
+	assertEqual(t, "gain min", gain.MinInteger, int32(-20))
+	assertEqual(t, "gain max", gain.MaxInteger, int32(20))
+	assertEqual(t, "gain category", gain.Category, int16(2))
+	assertEqual(t, "gain params in category", gain.ParametersInCategory, int16(1))
+
+	resonance := parameterProperties(params, 2)
+	assertEqual(t, "resonance short label", resonance.ShortLabel.String(), "Res")
+	assertEqual(t, "resonance category", resonance.Category, int16(1))
+
+	bypass := parameterProperties(params, 3)
+	assertEqual(t, "bypass flags", bypass.Flags, ParameterIsSwitch)
+}
+
+func TestParameterValue(t *testing.T) {
+	var changed []float32
+	p := Parameter{
+		ValueChangedFunc: func(value float32) {
+			changed = append(changed, value)
+		},
+	}
+	assertEqual(t, "initial value", p.Value(), float32(0))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_ = p.Value()
+		}
+	}()
+	p.SetValue(0.5)
+	<-done
+	assertEqual(t, "value", p.Value(), float32(0.5))
+	assertEqual(t, "changed", changed, []float32{0.5})
+}
+
+func TestParameterSmoother(t *testing.T) {
+	p := Parameter{
+		GetValueFunc: func(value float32) float32 {
+			return value * 2
+		},
+	}
+	s := ParameterSmoother{Parameter: &p}
+
+	p.SetValue(0.25)
+	v, step := s.Next(4)
+	assertEqual(t, "first block value", v, float32(0.5))
+	assertEqual(t, "first block step", step, float32(0))
+
+	p.SetValue(0.75)
+	v, step = s.Next(4)
+	assertEqual(t, "ramp value", v, float32(0.5))
+	assertEqual(t, "ramp step", step, float32(0.25))
+
+	v, step = s.Next(4)
+	assertEqual(t, "steady value", v, float32(1.5))
+	assertEqual(t, "steady step", step, float32(0))
+}
+
+func TestParameterQueue(t *testing.T) {
+	var (
+		gain = &Parameter{}
+		q    = ParameterQueue{
+			Controllers: map[uint8]int{7: 0},
+			parameters:  []*Parameter{gain},
+		}
+		in  = NewDoubleBuffer(1, 8)
+		out = NewDoubleBuffer(1, 8)
+	)
+	defer in.Free()
+	defer out.Free()
+	for i := range in.Channel(0) {
+		in.Channel(0)[i] = 1
+	}
+	process := func(in, out DoubleBuffer) {
+		for i := 0; i < in.Frames; i++ {
+			out.Channel(0)[i] = in.Channel(0)[i] * float64(gain.Value())
+		}
+	}
+
+	q.record(ParameterChange{Index: 0, Value: 0.5})
+	events := Events(
+		&MIDIEvent{DeltaFrames: 6, Data: [3]byte{0xB0, 7, 0}},
+		&MIDIEvent{DeltaFrames: 4, Data: [3]byte{0xB0, 7, 127}},
+		&MIDIEvent{DeltaFrames: 2, Data: [3]byte{0x90, 60, 100}},
+	)
+	defer events.Free()
+	q.recordEvents(events)
+
+	q.SplitDouble(in, out, process)
+	assertEqual(t, "changes", q.Changes(), []ParameterChange{
+		{Frame: 0, Index: 0, Value: 0.5},
+		{Frame: 4, Index: 0, Value: 1},
+		{Frame: 6, Index: 0, Value: 0},
+	})
+	assertEqual(t, "output", out.Channel(0), []float64{0.5, 0.5, 0.5, 0.5, 1, 1, 0, 0})
+
+	q.SplitDouble(in, out, process)
+	assertEqual(t, "no changes", len(q.Changes()), 0)
+	assertEqual(t, "output", out.Channel(0), []float64{0, 0, 0, 0, 0, 0, 0, 0})
+
+	// full queue applies the earliest change.
+	for i := 0; i <= parameterQueueSize; i++ {
+		q.record(ParameterChange{Frame: i, Index: 0, Value: float32(i) + 0.5})
+	}
+	assertEqual(t, "applied", gain.Value(), float32(0.5))
+	q.next()
+	changes := q.Changes()
+	assertEqual(t, "kept", len(changes), parameterQueueSize)
+	assertEqual(t, "first kept", changes[0].Frame, 1)
+	assertEqual(t, "capacity", cap(changes), parameterQueueSize)
+}
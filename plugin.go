@@ -5,15 +5,32 @@ package vst2
 //#include "include/plugin/plugin.c"
 import "C"
 import (
+	"fmt"
+	"log"
+	"os"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"unsafe"
 
 	"pipelined.dev/signal"
 )
 
+const (
+	// default processing properties, used until host sets them.
+	defaultSampleRate signal.Frequency = 44100
+	defaultBufferSize                  = 512
+)
+
 var (
+	// PluginAllocator allocates the plugin. If plugins are registered with
+	// RegisterPlugin, it's used to allocate the shell plugin.
 	PluginAllocator PluginAllocatorFunc
 
+	// PluginLogger is used to report errors of Go plugins, e.g.
+	// recovered panics. Set it to nil to disable reporting.
+	PluginLogger = log.New(os.Stderr, "vst2: ", log.LstdFlags)
+
 	// global state for callbacks.
 	plugins = struct {
 		sync.RWMutex
@@ -21,6 +38,9 @@ var (
 	}{
 		mapping: map[uintptr]*Plugin{},
 	}
+
+	// registered plugins, binary presents itself as a shell if not empty.
+	registry []registeredPlugin
 )
 
 type (
@@ -45,17 +65,57 @@ type (
 		ProcessDoubleFunc
 		ProcessFloatFunc
 		Parameters []*Parameter
+		// ParameterQueue is optional, if set then parameter changes are
+		// recorded in it.
+		ParameterQueue *ParameterQueue
+		Programs       []*Program
+		program        int
+		InputPins      []Pin
+		OutputPins     []Pin
+		// speaker arrangements are allocated in C memory, because host
+		// keeps pointers to them.
+		inputArrangement  *SpeakerArrangement
+		outputArrangement *SpeakerArrangement
+		// failed is set when plugin recovered from panic. Failed plugin
+		// outputs silence and ignores dispatch calls.
+		failed int32
+		// index of next plugin returned by shell.
+		nextShellPlugin int
+		// scratch buffers convert signal if plugin defines processing
+		// function only for one precision. scratchFrames is zero if
+		// conversion is not needed.
+		scratchPrecision    ProcessPrecision
+		scratchFrames       int
+		scratchInputDouble  DoubleBuffer
+		scratchOutputDouble DoubleBuffer
+		scratchInputFloat   FloatBuffer
+		scratchOutputFloat  FloatBuffer
 		dispatchFunc
 	}
 
 	// Dispatcher handles plugin dispatch calls from the host.
 	Dispatcher struct {
-		SetBufferSizeFunc func(size int)
-		CanDoFunc         func(PluginCanDoString) CanDoResponse // called by host to query the plugin about its capabilities
-		CloseFunc         func()                                // called by host right before deleting the plugin, use to free up resources
-		ProcessEventsFunc func(*EventsPtr)                      // called by host to pass events (e.g. MIDI events) along with their time stamps (frames) within the next processing block
-		GetChunkFunc      func(isPreset bool) []byte            // called by host to get the current state of the plugin. You should define GetChunkFunc & SetChunkFunc in pairs; defining both sets the PluginProgramChunks flag advertizing the capability to the host.
-		SetChunkFunc      func(data []byte, isPreset bool)      // called by host to set the current state of the plugin
+		SetBufferSizeFunc            func(size int)
+		CanDoFunc                    func(PluginCanDoString) CanDoResponse // called by host to query the plugin about its capabilities
+		CloseFunc                    func()                                // called by host right before deleting the plugin, use to free up resources
+		ProcessEventsFunc            func(*EventsPtr)                      // called by host to pass events (e.g. MIDI events) along with their time stamps (frames) within the next processing block
+		GetChunkFunc                 func(isPreset bool) []byte            // called by host to get the current state of the plugin. You should define GetChunkFunc & SetChunkFunc in pairs; defining both sets the PluginProgramChunks flag advertizing the capability to the host.
+		SetChunkFunc                 func(data []byte, isPreset bool)      // called by host to set the current state of the plugin
+		SetProgramFunc               func(index int)                       // called by host after plugin switched to the program with provided index and its parameter values are applied
+		GetStateFunc                 func() []byte                         // called by default chunk serializer to get additional state that is not stored in parameters. Used only if GetChunkFunc & SetChunkFunc are not defined.
+		SetStateFunc                 func(state []byte)                    // called by default chunk serializer to restore additional state. Used only if GetChunkFunc & SetChunkFunc are not defined.
+		MigrateChunkFunc             func(*Chunk)                          // called by default chunk serializer when chunk was saved by older plugin version, use it to upgrade the chunk in place
+		OpenFunc                     func()                                // called by host right after the plugin is instantiated
+		SetSampleRateFunc            func(signal.Frequency)                // called by host when sample rate is changed, use to (re)allocate sample rate dependent resources
+		ResumeFunc                   func()                                // called by host when plugin is enabled, processing will happen after this call
+		SuspendFunc                  func()                                // called by host when plugin is disabled, no processing happens after this call
+		StartProcessFunc             func()                                // called by host right before it starts to call process functions
+		StopProcessFunc              func()                                // called by host right after it stops to call process functions
+		SetProcessPrecisionFunc      func(ProcessPrecision)                // called by host to set precision of the upcoming processing
+		SetBypassFunc                func(bool) bool                       // called by host to enable or disable soft bypass. Return true if bypass is supported. Defining it makes plugin to respond positively to PluginCanBypass.
+		GetTailSizeFunc              func() int                            // called by host to get the size of the tail in samples, e.g. reverb time. Return 0 for default or 1 for no tail.
+		SetTotalSamplesToProcessFunc func(int)                             // called by host in offline mode before processing to set total number of samples that will be processed
+		SetSpeakerArrangementFunc    func(in, out SpeakerArrangement) bool // called by host to propose speaker arrangement. Return false to reject it. If not defined, arrangement is accepted when number of channels matches the plugin.
 	}
 
 	// ProcessDoubleFunc defines logic for double signal processing.
@@ -64,6 +124,12 @@ type (
 	// ProcessFloatFunc defines logic for float signal processing.
 	ProcessFloatFunc func(in, out FloatBuffer)
 
+	registeredPlugin struct {
+		uniqueID  [4]byte
+		name      string
+		allocator PluginAllocatorFunc
+	}
+
 	callbackHandler struct {
 		callback C.HostCallback
 	}
@@ -71,7 +137,7 @@ type (
 	dispatchFunc func(op PluginOpcode, index int32, value int64, ptr unsafe.Pointer, opt float32) int64
 )
 
-func (d Dispatcher) dispatchFunc(p Plugin) dispatchFunc {
+func (d Dispatcher) dispatchFunc(p *Plugin) dispatchFunc {
 	return func(op PluginOpcode, index int32, value int64, ptr unsafe.Pointer, opt float32) int64 {
 		switch op {
 		case plugClose:
@@ -79,21 +145,151 @@ func (d Dispatcher) dispatchFunc(p Plugin) dispatchFunc {
 				d.CloseFunc()
 			}
 			return 0
+		case plugOpen:
+			if d.OpenFunc == nil {
+				return 0
+			}
+			d.OpenFunc()
+		case plugSetSampleRate:
+			if d.SetSampleRateFunc == nil {
+				return 0
+			}
+			d.SetSampleRateFunc(signal.Frequency(opt))
+		case plugStateChanged:
+			if value == 0 && d.SuspendFunc != nil {
+				d.SuspendFunc()
+			}
+			if value != 0 && d.ResumeFunc != nil {
+				d.ResumeFunc()
+			}
+			return 0
+		case PlugStartProcess:
+			if d.StartProcessFunc == nil {
+				return 0
+			}
+			d.StartProcessFunc()
+		case PlugStopProcess:
+			if d.StopProcessFunc == nil {
+				return 0
+			}
+			d.StopProcessFunc()
+		case PlugSetProcessPrecision:
+			if d.SetProcessPrecisionFunc == nil {
+				return 0
+			}
+			if value == 0 {
+				d.SetProcessPrecisionFunc(ProcessFloat)
+			} else {
+				d.SetProcessPrecisionFunc(ProcessDouble)
+			}
+		case PlugSetBypass:
+			if d.SetBypassFunc == nil || !d.SetBypassFunc(value != 0) {
+				return 0
+			}
+		case PlugGetTailSize:
+			if d.GetTailSizeFunc == nil {
+				return 0
+			}
+			return int64(d.GetTailSizeFunc())
+		case PlugSetTotalSampleToProcess:
+			if d.SetTotalSamplesToProcessFunc == nil {
+				return 0
+			}
+			d.SetTotalSamplesToProcessFunc(int(value))
+			return value
+		case PlugGetInputProperties:
+			if int(index) < 0 || int(index) >= len(p.InputPins) {
+				return 0
+			}
+			*(*PinProperties)(ptr) = p.InputPins[index].properties()
+		case PlugGetOutputProperties:
+			if int(index) < 0 || int(index) >= len(p.OutputPins) {
+				return 0
+			}
+			*(*PinProperties)(ptr) = p.OutputPins[index].properties()
+		case plugSetSpeakerArrangement:
+			in := (*SpeakerArrangement)(valuePointer(value))
+			out := (*SpeakerArrangement)(ptr)
+			if in == nil || out == nil || !d.acceptSpeakerArrangement(p, *in, *out) {
+				return 0
+			}
+			*p.inputArrangement = *in
+			*p.outputArrangement = *out
+		case PlugGetSpeakerArrangement:
+			in := (**SpeakerArrangement)(valuePointer(value))
+			out := (**SpeakerArrangement)(ptr)
+			if in == nil || out == nil {
+				return 0
+			}
+			*in = p.inputArrangement
+			*out = p.outputArrangement
+		case plugSetProgram:
+			if !p.setProgram(int(value)) {
+				return 0
+			}
+			if d.SetProgramFunc != nil {
+				d.SetProgramFunc(int(value))
+			}
+		case plugGetProgram:
+			return int64(p.program)
+		case plugSetProgramName:
+			if len(p.Programs) == 0 {
+				return 0
+			}
+			s := (*ascii24)(ptr)
+			p.Programs[p.program].Name = s.String()
+		case plugGetProgramName:
+			if len(p.Programs) == 0 {
+				return 0
+			}
+			s := (*ascii24)(ptr)
+			copyASCII(s[:], p.Programs[p.program].Name)
+		case plugGetProgramNameIndexed:
+			if int(index) < 0 || int(index) >= len(p.Programs) {
+				return 0
+			}
+			s := (*ascii24)(ptr)
+			copyASCII(s[:], p.Programs[index].Name)
+		case plugCopyProgram:
+			if len(p.Programs) == 0 || int(index) < 0 || int(index) >= len(p.Programs) {
+				return 0
+			}
+			p.Programs[index].Name = p.Programs[p.program].Name
+			p.Programs[index].Values = p.parameterValues()
 		case plugGetParamName:
+			if int(index) < 0 || int(index) >= len(p.Parameters) {
+				return 0
+			}
 			s := (*ascii8)(ptr)
 			copyASCII(s[:], p.Parameters[index].Name)
 		case plugGetParamDisplay:
+			if int(index) < 0 || int(index) >= len(p.Parameters) {
+				return 0
+			}
 			s := (*ascii8)(ptr)
 			copyASCII(s[:], p.Parameters[index].GetValueLabel())
 		case plugGetParamLabel:
+			if int(index) < 0 || int(index) >= len(p.Parameters) {
+				return 0
+			}
 			s := (*ascii8)(ptr)
 			copyASCII(s[:], p.Parameters[index].Unit)
+		case plugGetParameterProperties:
+			if int(index) < 0 || int(index) >= len(p.Parameters) {
+				return 0
+			}
+			props := (*ParameterProperties)(ptr)
+			*props = parameterProperties(p.Parameters, int(index))
 		case PlugCanBeAutomated:
+			if int(index) < 0 || int(index) >= len(p.Parameters) {
+				return 0
+			}
 			if p.Parameters[index].NotAutomated {
 				return 0
 			}
 			return 1
 		case plugSetBufferSize:
+			p.resizeScratch(int(value))
 			if d.SetBufferSizeFunc == nil {
 				return 0
 			}
@@ -109,17 +305,31 @@ func (d Dispatcher) dispatchFunc(p Plugin) dispatchFunc {
 			copyASCII(s[:], p.Vendor)
 		case PlugGetPlugCategory:
 			return int64(p.Category)
-		case PlugCanDo:
-			if d.CanDoFunc == nil {
+		case PlugShellGetNextPlugin:
+			if p.Category != PluginCategoryShell || p.nextShellPlugin >= len(registry) {
 				return 0
 			}
+			next := registry[p.nextShellPlugin]
+			p.nextShellPlugin++
+			s := (*ascii64)(ptr)
+			copyASCII(s[:], next.name)
+			return int64(uniqueID(next.uniqueID))
+		case PlugCanDo:
 			s := PluginCanDoString(C.GoString((*C.char)(ptr)))
-			return int64(d.CanDoFunc(s))
+			if d.CanDoFunc != nil {
+				if r := d.CanDoFunc(s); r != MaybeCanDo {
+					return int64(r)
+				}
+			}
+			return int64(d.canDo(s))
 		case PlugProcessEvents:
+			var e *EventsPtr = (*EventsPtr)(ptr)
+			if p.ParameterQueue != nil {
+				p.ParameterQueue.recordEvents(e)
+			}
 			if d.ProcessEventsFunc == nil {
 				return 0
 			}
-			var e *EventsPtr = (*EventsPtr)(ptr)
 			d.ProcessEventsFunc(e)
 		case plugGetChunk:
 			if d.GetChunkFunc == nil {
@@ -145,6 +355,287 @@ func (d Dispatcher) dispatchFunc(p Plugin) dispatchFunc {
 	}
 }
 
+// RegisterPlugin registers plugin allocator under provided unique ID. If
+// any plugin is registered, the binary presents itself to host as a shell,
+// that allows to expose multiple plugins from one binary. The plugin is
+// instantiated when host requests its unique ID. Registration should
+// happen in init function, it's not safe for concurrent use.
+func RegisterPlugin(id [4]byte, name string, allocator PluginAllocatorFunc) {
+	for i := range registry {
+		if registry[i].uniqueID == id {
+			panic(fmt.Sprintf("plugin with unique id %q is already registered", id[:]))
+		}
+	}
+	registry = append(registry, registeredPlugin{
+		uniqueID:  id,
+		name:      name,
+		allocator: allocator,
+	})
+}
+
+// allocator returns allocator of the plugin that host requests. If
+// plugins are registered, host is asked for the current unique ID. Zero
+// ID means that shell plugin should be allocated.
+func (h callbackHandler) allocator(cp *C.CPlugin) PluginAllocatorFunc {
+	if len(registry) == 0 {
+		return PluginAllocator
+	}
+	id := int32(C.callbackHost(h.callback, cp, C.int(HostCurrentID), 0, 0, nil, 0))
+	if id == 0 {
+		return shellAllocator
+	}
+	for i := range registry {
+		if uniqueID(registry[i].uniqueID) == id {
+			return registry[i].allocator
+		}
+	}
+	panic(fmt.Sprintf("plugin with unique id %d is not registered", id))
+}
+
+// shellAllocator allocates the shell plugin. PluginAllocator is used to
+// describe the shell if it's defined.
+func shellAllocator(h Host) (Plugin, Dispatcher) {
+	var (
+		p Plugin
+		d Dispatcher
+	)
+	if PluginAllocator != nil {
+		p, d = PluginAllocator(h)
+	}
+	p.Category = PluginCategoryShell
+	return p, d
+}
+
+// canDo responds to capabilities queries based on defined dispatcher
+// functions. It's used when CanDoFunc is not defined or doesn't know the
+// answer. Every Go plugin can send events with Host.ProcessEvents.
+func (d Dispatcher) canDo(s PluginCanDoString) CanDoResponse {
+	switch s {
+	case PluginCanSendEvents, PluginCanSendMIDIEvent:
+		return YesCanDo
+	case PluginCanBypass:
+		if d.SetBypassFunc != nil {
+			return YesCanDo
+		}
+	}
+	return MaybeCanDo
+}
+
+// acceptSpeakerArrangement checks if proposed speaker arrangement can be
+// used by plugin.
+func (d Dispatcher) acceptSpeakerArrangement(p *Plugin, in, out SpeakerArrangement) bool {
+	if d.SetSpeakerArrangementFunc != nil {
+		return d.SetSpeakerArrangementFunc(in, out)
+	}
+	return int(in.NumChannels) == p.InputChannels && int(out.NumChannels) == p.OutputChannels
+}
+
+// valuePointer returns pointer that host passes in the integer value of
+// dispatch call. Pointer is reinterpreted from memory of the value, so it
+// isn't converted from uintptr.
+func valuePointer(value int64) unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(&value))
+}
+
+// newSpeakerArrangement allocates speaker arrangement in C memory and sets
+// the default type for provided number of channels.
+func newSpeakerArrangement(channels int) *SpeakerArrangement {
+	sa := (*SpeakerArrangement)(C.calloc(1, C.size_t(unsafe.Sizeof(SpeakerArrangement{}))))
+	sa.Type = defaultSpeakerArrangement(channels)
+	sa.NumChannels = int32(channels)
+	return sa
+}
+
+// fail switches plugin into failure mode and reports the recovered panic.
+func (p *Plugin) fail(bridge string, r interface{}) {
+	atomic.StoreInt32(&p.failed, 1)
+	logPanic(p.Name, bridge, r)
+}
+
+// logPanic reports recovered panic with PluginLogger.
+func logPanic(name, bridge string, r interface{}) {
+	if PluginLogger != nil {
+		PluginLogger.Printf("plugin %q failed in %s: %v\n%s", name, bridge, r, debug.Stack())
+	}
+}
+
+// isFailed returns true if plugin is in failure mode.
+func (p *Plugin) isFailed() bool {
+	return atomic.LoadInt32(&p.failed) == 1
+}
+
+// free releases C memory allocated for plugin.
+func (p *Plugin) free() {
+	C.free(unsafe.Pointer(p.inputArrangement))
+	C.free(unsafe.Pointer(p.outputArrangement))
+	p.freeScratch()
+}
+
+// bridgePrecision defines missing processing function. It converts
+// signal through scratch buffers and calls the defined function, so
+// host can process plugin with any precision.
+func (p *Plugin) bridgePrecision(frames int) {
+	switch {
+	case p.ProcessDoubleFunc != nil && p.ProcessFloatFunc == nil:
+		p.scratchPrecision = ProcessDouble
+		p.ProcessFloatFunc = p.processFloatAsDouble
+	case p.ProcessFloatFunc != nil && p.ProcessDoubleFunc == nil:
+		p.scratchPrecision = ProcessFloat
+		p.ProcessDoubleFunc = p.processDoubleAsFloat
+	default:
+		return
+	}
+	p.scratchFrames = frames
+	p.allocateScratch()
+}
+
+// resizeScratch reallocates scratch buffers if plugin bridges precision.
+func (p *Plugin) resizeScratch(frames int) {
+	if p.scratchFrames == 0 || p.scratchFrames == frames || frames <= 0 {
+		return
+	}
+	p.freeScratch()
+	p.scratchFrames = frames
+	p.allocateScratch()
+}
+
+func (p *Plugin) allocateScratch() {
+	switch p.scratchPrecision {
+	case ProcessDouble:
+		p.scratchInputDouble = NewDoubleBuffer(p.InputChannels, p.scratchFrames)
+		p.scratchOutputDouble = NewDoubleBuffer(p.OutputChannels, p.scratchFrames)
+	case ProcessFloat:
+		p.scratchInputFloat = NewFloatBuffer(p.InputChannels, p.scratchFrames)
+		p.scratchOutputFloat = NewFloatBuffer(p.OutputChannels, p.scratchFrames)
+	}
+}
+
+func (p *Plugin) freeScratch() {
+	if p.scratchFrames == 0 {
+		return
+	}
+	switch p.scratchPrecision {
+	case ProcessDouble:
+		p.scratchInputDouble.Free()
+		p.scratchOutputDouble.Free()
+	case ProcessFloat:
+		p.scratchInputFloat.Free()
+		p.scratchOutputFloat.Free()
+	}
+}
+
+// processFloatAsDouble converts float signal into double scratch
+// buffers and processes it with double precision. Scratch buffers grow
+// if host passes more frames than it announced with buffer size.
+func (p *Plugin) processFloatAsDouble(in, out FloatBuffer) {
+	if in.Frames > p.scratchFrames {
+		p.resizeScratch(in.Frames)
+	}
+	inDouble, outDouble := p.scratchInputDouble, p.scratchOutputDouble
+	inDouble.Frames, outDouble.Frames = in.Frames, out.Frames
+	inDouble.CopyFloat(in)
+	p.ProcessDoubleFunc(inDouble, outDouble)
+	out.CopyDouble(outDouble)
+}
+
+// processDoubleAsFloat converts double signal into float scratch
+// buffers and processes it with float precision. Scratch buffers grow
+// if host passes more frames than it announced with buffer size.
+func (p *Plugin) processDoubleAsFloat(in, out DoubleBuffer) {
+	if in.Frames > p.scratchFrames {
+		p.resizeScratch(in.Frames)
+	}
+	inFloat, outFloat := p.scratchInputFloat, p.scratchOutputFloat
+	inFloat.Frames, outFloat.Frames = in.Frames, out.Frames
+	inFloat.CopyDouble(in)
+	p.ProcessFloatFunc(inFloat, outFloat)
+	out.CopyFloat(outFloat)
+}
+
+// defaultChunkFuncs returns chunk functions that save and load parameter
+// values, programs and additional state provided by dispatcher.
+func (d Dispatcher) defaultChunkFuncs(p *Plugin) (func(bool) []byte, func([]byte, bool)) {
+	get := func(isPreset bool) []byte {
+		c := Chunk{
+			Version: p.Version,
+			Program: p.program,
+			Values:  p.parameterValues(),
+		}
+		if !isPreset && len(p.Programs) > 0 {
+			p.Programs[p.program].Values = c.Values
+			c.Programs = make([]Program, len(p.Programs))
+			for i := range p.Programs {
+				c.Programs[i] = *p.Programs[i]
+			}
+		}
+		if d.GetStateFunc != nil {
+			c.State = d.GetStateFunc()
+		}
+		data, _ := c.MarshalBinary()
+		return data
+	}
+	set := func(data []byte, isPreset bool) {
+		var c Chunk
+		if err := c.UnmarshalBinary(data); err != nil {
+			return
+		}
+		if c.Version < p.Version && d.MigrateChunkFunc != nil {
+			d.MigrateChunkFunc(&c)
+		}
+		if !isPreset {
+			for i := 0; i < len(c.Programs) && i < len(p.Programs); i++ {
+				*p.Programs[i] = c.Programs[i]
+			}
+			if c.Program >= 0 && c.Program < len(p.Programs) {
+				p.program = c.Program
+			}
+		}
+		for i := 0; i < len(c.Values) && i < len(p.Parameters); i++ {
+			p.Parameters[i].SetValue(c.Values[i])
+		}
+		if d.SetStateFunc != nil {
+			d.SetStateFunc(c.State)
+		}
+	}
+	return get, set
+}
+
+// hasStateFuncs returns true if dispatcher extends default chunk
+// serializer.
+func (d Dispatcher) hasStateFuncs() bool {
+	return d.GetStateFunc != nil || d.SetStateFunc != nil || d.MigrateChunkFunc != nil
+}
+
+// setProgram stores current parameter values into the current program and
+// then applies values of the program with provided index. Returns false if
+// index is out of range.
+func (p *Plugin) setProgram(index int) bool {
+	if index < 0 || index >= len(p.Programs) {
+		return false
+	}
+	p.Programs[p.program].Values = p.parameterValues()
+	p.program = index
+	p.applyProgram()
+	return true
+}
+
+// applyProgram sets parameter values from the current program.
+func (p *Plugin) applyProgram() {
+	values := p.Programs[p.program].Values
+	for i := 0; i < len(values) && i < len(p.Parameters); i++ {
+		p.Parameters[i].SetValue(values[i])
+	}
+}
+
+// parameterValues returns a snapshot of current parameter values.
+func (p *Plugin) parameterValues() []float32 {
+	values := make([]float32, len(p.Parameters))
+	for i := range p.Parameters {
+		values[i] = p.Parameters[i].Value()
+	}
+	return values
+}
+
 func (h callbackHandler) host(cp *C.CPlugin) Host {
 	return Host{
 		GetSampleRate: func() signal.Frequency {
@@ -159,9 +650,20 @@ func (h callbackHandler) host(cp *C.CPlugin) Host {
 		GetTimeInfo: func(flags TimeInfoFlag) *TimeInfo {
 			return (*TimeInfo)(unsafe.Pointer(uintptr(C.callbackHost(h.callback, cp, C.int(HostGetTime), 0, C.int64_t(flags), nil, 0))))
 		},
+		GetTempoAt: func(samplePos int) float64 {
+			return float64(C.callbackHost(h.callback, cp, C.int(hostTempoAt), 0, C.int64_t(samplePos), nil, 0)) / 10000
+		},
 		UpdateDisplay: func() bool {
 			return C.callbackHost(h.callback, cp, C.int(HostUpdateDisplay), 0, 0, nil, 0) > 0
 		},
+		ProcessEvents: func(events ...Event) bool {
+			e := Events(events...)
+			defer e.Free()
+			return C.callbackHost(h.callback, cp, C.int(HostProcessEvents), 0, 0, unsafe.Pointer(e), 0) > 0
+		},
+		Automate: func(index int, value float32) {
+			C.callbackHost(h.callback, cp, C.int(HostAutomate), C.int32_t(index), 0, nil, C.float(value))
+		},
 	}
 }
 
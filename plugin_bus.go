@@ -0,0 +1,64 @@
+// +build plugin
+
+package vst2
+
+type (
+	// DoubleBuses is a bus-aware view of buffers passed to
+	// ProcessDoubleFunc. Buffers share memory with host buffers.
+	DoubleBuses struct {
+		Main      DoubleBuffer
+		Sidechain DoubleBuffer
+		Output    DoubleBuffer
+	}
+
+	// FloatBuses is a bus-aware view of buffers passed to
+	// ProcessFloatFunc. Buffers share memory with host buffers.
+	FloatBuses struct {
+		Main      FloatBuffer
+		Sidechain FloatBuffer
+		Output    FloatBuffer
+	}
+)
+
+// SplitDoubleBuses splits buffers passed to ProcessDoubleFunc into buses
+// according to input pins layout. Input channels are split by the first
+// input pin with Sidechain set, all input channels belong to main bus if
+// there is no such pin.
+func SplitDoubleBuses(pins []Pin, in, out DoubleBuffer) DoubleBuses {
+	main := MainInputChannels(pins, in.Channels())
+	return DoubleBuses{
+		Main:      DoubleBuffer{Frames: in.Frames, data: in.data[:main:main]},
+		Sidechain: DoubleBuffer{Frames: in.Frames, data: in.data[main:]},
+		Output:    out,
+	}
+}
+
+// SplitFloatBuses splits buffers passed to ProcessFloatFunc into buses
+// according to input pins layout. Input channels are split by the first
+// input pin with Sidechain set, all input channels belong to main bus if
+// there is no such pin.
+func SplitFloatBuses(pins []Pin, in, out FloatBuffer) FloatBuses {
+	main := MainInputChannels(pins, in.Channels())
+	return FloatBuses{
+		Main:      FloatBuffer{Frames: in.Frames, data: in.data[:main:main]},
+		Sidechain: FloatBuffer{Frames: in.Frames, data: in.data[main:]},
+		Output:    out,
+	}
+}
+
+// MainInputChannels returns number of channels in main input bus for
+// provided input pins layout, limited by number of input channels.
+func MainInputChannels(pins []Pin, channels int) int {
+	for i := range pins {
+		if pins[i].Sidechain {
+			return min(i, channels)
+		}
+	}
+	return channels
+}
+
+// SidechainChannels returns number of channels in sidechain input bus for
+// provided input pins layout and number of input channels.
+func SidechainChannels(pins []Pin, channels int) int {
+	return channels - MainInputChannels(pins, channels)
+}
@@ -8,18 +8,37 @@ import (
 	"unsafe"
 )
 
-// instantiate go plugin
+// instantiate go plugin, returns 0 if allocation failed.
 //export newGoPlugin
-func newGoPlugin(cp *C.CPlugin, c C.HostCallback) {
+func newGoPlugin(cp *C.CPlugin, c C.HostCallback) (ok int32) {
+	defer func() {
+		if r := recover(); r != nil {
+			logPanic("", "newGoPlugin", r)
+			ok = 0
+		}
+	}()
 	loadHook()
-	p, d := PluginAllocator(callbackHandler{c}.host(cp))
+	h := callbackHandler{c}
+	newPlugin(cp, h.host(cp), h.allocator(cp))
+	return 1
+}
+
+// newPlugin allocates plugin and sets up C plugin structure.
+func newPlugin(cp *C.CPlugin, h Host, allocator PluginAllocatorFunc) {
+	p, d := allocator(h)
 	cp.magic = C.int(EffectMagic)
 	cp.numInputs = C.int(p.InputChannels)
 	cp.numOutputs = C.int(p.OutputChannels)
 	cp.numParams = C.int(len(p.Parameters))
+	cp.numPrograms = C.int(len(p.Programs))
 	cp.version = C.int(p.Version)
-	cp.uniqueID = C.int(uint(p.UniqueID[0])<<24 | uint(p.UniqueID[1])<<16 | uint(p.UniqueID[2])<<8 | uint(p.UniqueID[3])<<0)
+	cp.uniqueID = C.int(uniqueID(p.UniqueID))
 	cp.flags = cp.flags | C.int(p.Flags)
+	p.inputArrangement = newSpeakerArrangement(p.InputChannels)
+	p.outputArrangement = newSpeakerArrangement(p.OutputChannels)
+	// plugin that defines only one precision is bridged to the other one,
+	// because hosts may support only one of them.
+	p.bridgePrecision(defaultBufferSize)
 	if p.ProcessDoubleFunc != nil {
 		cp.flags = cp.flags | C.int(PluginDoubleProcessing)
 		p.inputDouble = DoubleBuffer{data: make([]*C.double, p.InputChannels)}
@@ -31,34 +50,63 @@ func newGoPlugin(cp *C.CPlugin, c C.HostCallback) {
 		p.outputFloat = FloatBuffer{data: make([]*C.float, p.OutputChannels)}
 	}
 
-	// GetChunk and SetChunk should be defined in pairs. If both are
-	// defined, we advertise the capability to save and load plugin settings,
-	// by settings flag PluginProgramChunks.
-	if d.GetChunkFunc != nil && d.SetChunkFunc != nil {
+	// GetChunk and SetChunk should be defined in pairs. If any of them is
+	// not defined, default chunk serializer is used. We advertise the
+	// capability to save and load plugin settings, by settings flag
+	// PluginProgramChunks, only if plugin defines chunk or state
+	// functions. Otherwise host saves parameter values itself.
+	if d.GetChunkFunc != nil && d.SetChunkFunc != nil || d.hasStateFuncs() {
 		cp.flags = cp.flags | C.int(PluginProgramChunks)
 	}
-	p.dispatchFunc = d.dispatchFunc(p)
+	if d.GetChunkFunc == nil || d.SetChunkFunc == nil {
+		d.GetChunkFunc, d.SetChunkFunc = d.defaultChunkFuncs(&p)
+	}
+	for i := range p.Parameters {
+		p.Parameters[i].SetValue(p.Parameters[i].Default)
+	}
+	if p.ParameterQueue != nil {
+		p.ParameterQueue.parameters = p.Parameters
+	}
+	// programs are optional, but if they are defined then the first one
+	// is active when plugin is instantiated.
+	if len(p.Programs) > 0 {
+		p.applyProgram()
+	}
+	p.dispatchFunc = d.dispatchFunc(&p)
 	plugins.Lock()
 	plugins.mapping[uintptr(unsafe.Pointer(cp))] = &p
 	plugins.Unlock()
 }
 
 //export dispatchPluginBridge
-// global dispatch, calls real plugin dispatch.
-func dispatchPluginBridge(cp *C.CPlugin, opcode int32, index int32, value int64, ptr unsafe.Pointer, opt float32) int64 {
+// global dispatch, calls real plugin dispatch. Failed plugin ignores all
+// calls except plugClose, so CloseFunc can release resources.
+func dispatchPluginBridge(cp *C.CPlugin, opcode int32, index int32, value int64, ptr unsafe.Pointer, opt float32) (ret int64) {
 	p := getPlugin(cp)
 	pluginOpcode := PluginOpcode(opcode)
-	ret := p.dispatchFunc(pluginOpcode, index, value, ptr, opt)
 	if pluginOpcode == plugClose {
-		plugins.RLock()
-		defer plugins.RUnlock()
-		delete(plugins.mapping, uintptr(unsafe.Pointer(cp)))
+		defer func() {
+			p.free()
+			plugins.Lock()
+			defer plugins.Unlock()
+			delete(plugins.mapping, uintptr(unsafe.Pointer(cp)))
+		}()
 	}
-	return ret
+	if p.isFailed() && pluginOpcode != plugClose {
+		return 0
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			p.fail(pluginOpcode.String(), r)
+			ret = 0
+		}
+	}()
+	return p.dispatchFunc(pluginOpcode, index, value, ptr, opt)
 }
 
 //export processDoublePluginBridge
-// global processDouble, calls real plugin processDouble.
+// global processDouble, calls real plugin processDouble. Failed plugin
+// outputs silence.
 func processDoublePluginBridge(cp *C.CPlugin, in, out **C.double, sampleFrames int32) {
 	p := getPlugin(cp)
 	for i := range p.inputDouble.data {
@@ -69,12 +117,22 @@ func processDoublePluginBridge(cp *C.CPlugin, in, out **C.double, sampleFrames i
 	}
 	p.inputDouble.Frames = int(sampleFrames)
 	p.outputDouble.Frames = int(sampleFrames)
+	if p.isFailed() {
+		p.outputDouble.zero()
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			p.fail("processDouble", r)
+			p.outputDouble.zero()
+		}
+	}()
 	p.ProcessDoubleFunc(p.inputDouble, p.outputDouble)
-	return
 }
 
 //export processFloatPluginBridge
-// global processFloat, calls real plugin processFloat.
+// global processFloat, calls real plugin processFloat. Failed plugin
+// outputs silence.
 func processFloatPluginBridge(cp *C.CPlugin, in, out **C.float, sampleFrames int32) {
 	p := getPlugin(cp)
 	for i := range p.inputFloat.data {
@@ -85,18 +143,55 @@ func processFloatPluginBridge(cp *C.CPlugin, in, out **C.float, sampleFrames int
 	}
 	p.inputFloat.Frames = int(sampleFrames)
 	p.outputFloat.Frames = int(sampleFrames)
+	if p.isFailed() {
+		p.outputFloat.zero()
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			p.fail("processFloat", r)
+			p.outputFloat.zero()
+		}
+	}()
 	p.ProcessFloatFunc(p.inputFloat, p.outputFloat)
-	return
 }
 
 //export getParameterPluginBridge
 // global getParameter, calls real plugin getParameter.
-func getParameterPluginBridge(cp *C.CPlugin, index int32) float32 {
-	return getPlugin(cp).Parameters[index].Value
+func getParameterPluginBridge(cp *C.CPlugin, index int32) (value float32) {
+	p := getPlugin(cp)
+	if p.isFailed() {
+		return 0
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			p.fail("getParameter", r)
+			value = 0
+		}
+	}()
+	if int(index) < 0 || int(index) >= len(p.Parameters) {
+		return 0
+	}
+	return p.Parameters[index].Value()
 }
 
 //export setParameterPluginBridge
 // global setParameter, calls real plugin setParameter.
 func setParameterPluginBridge(cp *C.CPlugin, index int32, value float32) {
-	getPlugin(cp).Parameters[index].Value = value
+	p := getPlugin(cp)
+	if p.isFailed() {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			p.fail("setParameter", r)
+		}
+	}()
+	if int(index) < 0 || int(index) >= len(p.Parameters) {
+		return
+	}
+	p.Parameters[index].SetValue(value)
+	if p.ParameterQueue != nil {
+		p.ParameterQueue.record(ParameterChange{Index: int(index), Value: value})
+	}
 }
@@ -0,0 +1,158 @@
+// +build plugin
+
+package vst2
+
+import (
+	"context"
+	"fmt"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+// processorPlugin holds pipe processor and properties it was allocated
+// with.
+type processorPlugin struct {
+	allocatorFunc  pipe.ProcessorAllocatorFunc
+	inputChannels  int
+	outputChannels int
+	sampleRate     signal.Frequency
+	bufferSize     int
+	processor      pipe.Processor
+	// allocated is false if processor must be (re)allocated before
+	// processing.
+	allocated bool
+	started   bool
+}
+
+// ProcessorPlugin extends provided plugin and dispatcher to process
+// signal with pipe processor. Processor is allocated with plugin input
+// channels, sample rate and buffer size set by host. It's reallocated
+// when sample rate or buffer size is changed. Processor must output the
+// same number of channels as plugin has outputs.
+//
+// Processor is started when plugin is resumed and flushed when plugin
+// is suspended or closed. Processing functions and dispatcher hooks
+// defined in provided plugin and dispatcher are kept, processing
+// functions are replaced. Processor receives signal.Floating views of
+// host buffers, so samples are not copied:
+//
+//	vst2.PluginAllocator = func(h vst2.Host) (vst2.Plugin, vst2.Dispatcher) {
+//		return vst2.ProcessorPlugin(vst2.Plugin{
+//			Name:           "Gain",
+//			InputChannels:  2,
+//			OutputChannels: 2,
+//		}, vst2.Dispatcher{}, gain.Processor(0.5))
+//	}
+func ProcessorPlugin(p Plugin, d Dispatcher, fn pipe.ProcessorAllocatorFunc) (Plugin, Dispatcher) {
+	pp := processorPlugin{
+		allocatorFunc:  fn,
+		inputChannels:  p.InputChannels,
+		outputChannels: p.OutputChannels,
+		sampleRate:     defaultSampleRate,
+		bufferSize:     defaultBufferSize,
+	}
+	p.ProcessDoubleFunc = func(in, out DoubleBuffer) {
+		pp.process(in.Floating(), out.Floating())
+	}
+	p.ProcessFloatFunc = func(in, out FloatBuffer) {
+		pp.process(in.Floating(), out.Floating())
+	}
+
+	setSampleRate := d.SetSampleRateFunc
+	d.SetSampleRateFunc = func(sampleRate signal.Frequency) {
+		if pp.sampleRate != sampleRate {
+			pp.sampleRate = sampleRate
+			pp.allocated = false
+		}
+		if setSampleRate != nil {
+			setSampleRate(sampleRate)
+		}
+	}
+	setBufferSize := d.SetBufferSizeFunc
+	d.SetBufferSizeFunc = func(size int) {
+		if pp.bufferSize != size {
+			pp.bufferSize = size
+			pp.allocated = false
+		}
+		if setBufferSize != nil {
+			setBufferSize(size)
+		}
+	}
+	resume := d.ResumeFunc
+	d.ResumeFunc = func() {
+		pp.start()
+		if resume != nil {
+			resume()
+		}
+	}
+	suspend := d.SuspendFunc
+	d.SuspendFunc = func() {
+		if suspend != nil {
+			suspend()
+		}
+		pp.flush()
+	}
+	closePlugin := d.CloseFunc
+	d.CloseFunc = func() {
+		if closePlugin != nil {
+			closePlugin()
+		}
+		pp.flush()
+	}
+	return p, d
+}
+
+// start allocates the processor if needed and starts it.
+func (pp *processorPlugin) start() {
+	if pp.started && pp.allocated {
+		return
+	}
+	pp.flush()
+	if !pp.allocated {
+		processor, err := pp.allocatorFunc(mutable.Immutable(), pp.bufferSize, pipe.SignalProperties{
+			SampleRate: pp.sampleRate,
+			Channels:   pp.inputChannels,
+		})
+		if err != nil {
+			panic(fmt.Errorf("error allocating processor: %w", err))
+		}
+		if processor.Channels != pp.outputChannels {
+			panic(fmt.Errorf("processor outputs %d channels, plugin has %d outputs", processor.Channels, pp.outputChannels))
+		}
+		pp.processor = processor
+		pp.allocated = true
+	}
+	if pp.processor.StartFunc != nil {
+		if err := pp.processor.StartFunc(context.Background()); err != nil {
+			panic(fmt.Errorf("error starting processor: %w", err))
+		}
+	}
+	pp.started = true
+}
+
+// flush flushes the processor if it was started.
+func (pp *processorPlugin) flush() {
+	if !pp.started {
+		return
+	}
+	pp.started = false
+	if pp.processor.FlushFunc != nil {
+		if err := pp.processor.FlushFunc(context.Background()); err != nil {
+			panic(fmt.Errorf("error flushing processor: %w", err))
+		}
+	}
+}
+
+// process calls the processor. If host didn't resume the plugin or
+// changed its properties without suspending, processor is started
+// first.
+func (pp *processorPlugin) process(in, out signal.Floating) {
+	if !pp.started || !pp.allocated {
+		pp.start()
+	}
+	if _, err := pp.processor.ProcessFunc(in, out); err != nil {
+		panic(fmt.Errorf("error processing: %w", err))
+	}
+}
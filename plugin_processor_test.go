@@ -0,0 +1,68 @@
+// +build plugin,harness
+
+package vst2_test
+
+import (
+	"context"
+	"testing"
+
+	"pipelined.dev/audio/vst2"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+func TestProcessorPlugin(t *testing.T) {
+	var (
+		allocated []pipe.SignalProperties
+		sizes     []int
+		flushed   int
+	)
+	gain := func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Processor, error) {
+		allocated = append(allocated, props)
+		sizes = append(sizes, bufferSize)
+		return pipe.Processor{
+			SignalProperties: props,
+			ProcessFunc: func(in, out signal.Floating) (int, error) {
+				for i := 0; i < in.Len(); i++ {
+					out.SetSample(i, in.Sample(i)*2)
+				}
+				return in.Length(), nil
+			},
+			FlushFunc: func(context.Context) error {
+				flushed++
+				return nil
+			},
+		}, nil
+	}
+	allocator := func(h vst2.Host) (vst2.Plugin, vst2.Dispatcher) {
+		return vst2.ProcessorPlugin(vst2.Plugin{
+			Name:           "Gain",
+			InputChannels:  2,
+			OutputChannels: 2,
+		}, vst2.Dispatcher{}, gain)
+	}
+	h, err := vst2.NewHarness(allocator, vst2.Host{})
+	assertEqual(t, "harness error", err, nil)
+	defer h.Close()
+
+	h.SetSampleRate(48000)
+	h.SetBufferSize(2)
+	h.Resume()
+	out := h.ProcessDouble([][]float64{{1, 2}, {3, 4}}, 2)
+	assertEqual(t, "double output", out, [][]float64{{2, 4}, {6, 8}})
+	outFloat := h.ProcessFloat([][]float32{{1, 2}, {3, 4}}, 2)
+	assertEqual(t, "float output", outFloat, [][]float32{{2, 4}, {6, 8}})
+	assertEqual(t, "allocated", allocated, []pipe.SignalProperties{{SampleRate: 48000, Channels: 2}})
+
+	h.Suspend()
+	h.SetBufferSize(4)
+	h.Resume()
+	assertEqual(t, "flushed", flushed, 1)
+	assertEqual(t, "sizes", sizes, []int{2, 4})
+
+	h.Suspend()
+	h.Resume()
+	assertEqual(t, "reallocated", len(allocated), 2)
+	assertEqual(t, "flushed after resume", flushed, 2)
+}
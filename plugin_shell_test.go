@@ -0,0 +1,66 @@
+// +build plugin,harness
+
+package vst2
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestShell(t *testing.T) {
+	defer func() { registry = nil }()
+	registered := func(channels int) PluginAllocatorFunc {
+		return func(Host) (Plugin, Dispatcher) {
+			return Plugin{
+				InputChannels:     channels,
+				OutputChannels:    channels,
+				ProcessDoubleFunc: func(in, out DoubleBuffer) {},
+			}, Dispatcher{}
+		}
+	}
+	mono, stereo := [4]byte{'M', 'o', 'n', 'o'}, [4]byte{'S', 't', 'e', 'r'}
+	RegisterPlugin(mono, "Mono", registered(1))
+	RegisterPlugin(stereo, "Stereo", registered(2))
+
+	t.Run("duplicate id", func(t *testing.T) {
+		defer func() {
+			assertEqual(t, "panic", recover() != nil, true)
+		}()
+		RegisterPlugin(mono, "Other", registered(3))
+	})
+	t.Run("select by id", func(t *testing.T) {
+		h, err := NewHarness(nil, Host{
+			GetCurrentID: func() [4]byte { return stereo },
+		})
+		assertEqual(t, "harness error", err, nil)
+		defer h.Close()
+		assertEqual(t, "inputs", h.NumInputs(), 2)
+	})
+	t.Run("unknown id", func(t *testing.T) {
+		_, err := NewHarness(nil, Host{
+			GetCurrentID: func() [4]byte { return [4]byte{'N', 'o', 'n', 'e'} },
+		})
+		assertEqual(t, "harness error", err != nil, true)
+	})
+	t.Run("enumerate shell", func(t *testing.T) {
+		h, err := NewHarness(nil, Host{})
+		assertEqual(t, "harness error", err, nil)
+		defer h.Close()
+		assertEqual(t, "category", PluginCategory(h.Dispatch(PlugGetPlugCategory, 0, 0, nil, 0)), PluginCategoryShell)
+		var (
+			name  ascii64
+			ids   []int64
+			names []string
+		)
+		for {
+			id := h.Dispatch(PlugShellGetNextPlugin, 0, 0, unsafe.Pointer(&name), 0)
+			if id == 0 {
+				break
+			}
+			ids = append(ids, id)
+			names = append(names, name.String())
+		}
+		assertEqual(t, "ids", ids, []int64{int64(uniqueID(mono)), int64(uniqueID(stereo))})
+		assertEqual(t, "names", names, []string{"Mono", "Stereo"})
+	})
+}
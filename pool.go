@@ -0,0 +1,118 @@
+package vst2
+
+import "sync"
+
+// BufferPool keeps released buffers to reuse their C memory. Buffers are
+// grouped by number of channels, any buffer with the same number of
+// channels is resized to requested number of frames. Nil pool allocates
+// new buffers and frees released ones. It's safe for concurrent use.
+type BufferPool struct {
+	mu     sync.Mutex
+	double map[int][]DoubleBuffer
+	float  map[int][]FloatBuffer
+}
+
+// GetDouble returns double buffer with provided dimensions.
+func (p *BufferPool) GetDouble(channels, frames int) DoubleBuffer {
+	if p == nil {
+		return NewDoubleBuffer(channels, frames)
+	}
+	p.mu.Lock()
+	buffers := p.double[channels]
+	if len(buffers) == 0 {
+		p.mu.Unlock()
+		return NewDoubleBuffer(channels, frames)
+	}
+	// prefer buffer that doesn't need reallocation.
+	i := len(buffers) - 1
+	for j := range buffers {
+		if buffers[j].capacity >= frames {
+			i = j
+			break
+		}
+	}
+	b := buffers[i]
+	buffers[i] = buffers[len(buffers)-1]
+	p.double[channels] = buffers[:len(buffers)-1]
+	p.mu.Unlock()
+	b.Resize(frames)
+	return b
+}
+
+// PutDouble releases double buffer into the pool. Buffer must not be used
+// after this call.
+func (p *BufferPool) PutDouble(b DoubleBuffer) {
+	if p == nil {
+		b.Free()
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.double == nil {
+		p.double = make(map[int][]DoubleBuffer)
+	}
+	p.double[len(b.data)] = append(p.double[len(b.data)], b)
+}
+
+// GetFloat returns float buffer with provided dimensions.
+func (p *BufferPool) GetFloat(channels, frames int) FloatBuffer {
+	if p == nil {
+		return NewFloatBuffer(channels, frames)
+	}
+	p.mu.Lock()
+	buffers := p.float[channels]
+	if len(buffers) == 0 {
+		p.mu.Unlock()
+		return NewFloatBuffer(channels, frames)
+	}
+	// prefer buffer that doesn't need reallocation.
+	i := len(buffers) - 1
+	for j := range buffers {
+		if buffers[j].capacity >= frames {
+			i = j
+			break
+		}
+	}
+	b := buffers[i]
+	buffers[i] = buffers[len(buffers)-1]
+	p.float[channels] = buffers[:len(buffers)-1]
+	p.mu.Unlock()
+	b.Resize(frames)
+	return b
+}
+
+// PutFloat releases float buffer into the pool. Buffer must not be used
+// after this call.
+func (p *BufferPool) PutFloat(b FloatBuffer) {
+	if p == nil {
+		b.Free()
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.float == nil {
+		p.float = make(map[int][]FloatBuffer)
+	}
+	p.float[len(b.data)] = append(p.float[len(b.data)], b)
+}
+
+// Free releases memory of all buffers in the pool. Nil pool keeps no
+// buffers and Free does nothing.
+func (p *BufferPool) Free() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, buffers := range p.double {
+		for _, b := range buffers {
+			b.Free()
+		}
+	}
+	for _, buffers := range p.float {
+		for _, b := range buffers {
+			b.Free()
+		}
+	}
+	p.double, p.float = nil, nil
+}
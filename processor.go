@@ -4,6 +4,8 @@ package vst2
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	"pipelined.dev/pipe"
 	"pipelined.dev/pipe/mutable"
@@ -18,12 +20,50 @@ type (
 		sampleRate signal.Frequency
 		plugin     *Plugin
 		progressFn ProgressProcessedFunc
+		// BufferPool is optional, if set then processing buffers are
+		// taken from it and released into it when processor is flushed.
+		// Share it across processors to reuse C memory.
+		BufferPool *BufferPool
+		// InputMap routes pipe signal into plugin inputs and OutputMap
+		// routes plugin outputs into pipe signal. Number of rows in
+		// OutputMap defines number of channels in processor output. If
+		// not set, channels are routed into channels with the same index
+		// and extra plugin inputs are silent.
+		InputMap  ChannelMap
+		OutputMap ChannelMap
+		// Sidechain is optional, if set then key signal is passed into
+		// sidechain inputs of the plugin. InputMap routes pipe signal
+		// into main inputs only.
+		Sidechain *Sidechain
+		// Transport provides time info for the plugin and is advanced
+		// after every processed block. It's playing at 120 BPM in 4/4
+		// by default. If set to nil, time info is not provided.
+		Transport *Transport
+		// timeInfo is C memory returned to plugin by GetTimeInfo
+		// callback, every processor has its own copy. It's released when
+		// plugin is closed.
+		timeInfo *TimeInfo
+		// precision is used if forcePrecision is set.
+		precision      ProcessPrecision
+		forcePrecision bool
 	}
 
 	// ProcessorInitFunc applies configuration on plugin before starting it
 	// in the processor routine.
 	ProcessorInitFunc func(*Plugin)
 
+	// routing defines how pipe channels are mapped into plugin channels.
+	// Nil map means that signal is copied without routing. Plugin inputs
+	// starting from keyStart receive key signal if sidechain is set.
+	routing struct {
+		pipeIn, pipeOut     int
+		pluginIn, pluginOut int
+		in, out             ChannelMap
+		keyStart            int
+		key                 *Sidechain
+		keyMap              ChannelMap
+	}
+
 	// HostProgressProcessed is executed by processor after every process
 	// call.
 	ProgressProcessedFunc func(int)
@@ -31,38 +71,93 @@ type (
 
 // Processor represents vst2 sound processor. Processor always overrides
 // GetBufferSize and GetSampleRate callbacks, because this vaules are
-// injected when processor is allocated by pipe.
+// injected when processor is allocated by pipe. If GetTimeInfo and
+// GetTempoAt callbacks are not provided, they are answered by processor
+// transport.
 func (v *VST) Processor(h Host, progressFn ProgressProcessedFunc) *Processor {
-	processor := Processor{}
+	processor := Processor{
+		progressFn: progressFn,
+		Transport:  NewTransport(120, 4, 4),
+	}
+	processor.Transport.Play()
 	h.GetBufferSize = func() int {
 		return processor.bufferSize
 	}
 	h.GetSampleRate = func() signal.Frequency {
 		return processor.sampleRate
 	}
-	plugin := v.Plugin(h.Callback())
-	return &Processor{
-		plugin:     plugin,
-		progressFn: progressFn,
+	if h.GetTimeInfo == nil {
+		processor.timeInfo = newTimeInfo()
+		h.GetTimeInfo = func(flags TimeInfoFlag) *TimeInfo {
+			if processor.Transport == nil || processor.timeInfo == nil {
+				return nil
+			}
+			*processor.timeInfo = processor.Transport.TimeInfo(flags)
+			return processor.timeInfo
+		}
+	}
+	if h.GetTempoAt == nil {
+		h.GetTempoAt = func(samplePos int) float64 {
+			return processor.Transport.TempoAt(samplePos)
+		}
+	}
+	processor.plugin = v.Plugin(h.Callback())
+	if processor.timeInfo != nil {
+		if processor.plugin == nil {
+			freeTimeInfo(processor.timeInfo)
+			processor.timeInfo = nil
+		} else {
+			processor.plugin.release = func() {
+				freeTimeInfo(processor.timeInfo)
+				processor.timeInfo = nil
+			}
+		}
+	}
+	return &processor
+}
+
+// Close closes the plugin of the processor and releases its resources.
+// Processor cannot be used after it's closed.
+func (p *Processor) Close() {
+	if p.plugin != nil {
+		p.plugin.Close()
 	}
 }
 
+// ForcePrecision makes processor to process signal with provided
+// precision. By default, double precision is used if plugin supports it.
+func (p *Processor) ForcePrecision(precision ProcessPrecision) {
+	p.precision = precision
+	p.forcePrecision = true
+}
+
 // Allocator returns pipe processor allocator that can be plugged into line.
 func (p *Processor) Allocator(init ProcessorInitFunc) pipe.ProcessorAllocatorFunc {
 	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Processor, error) {
 		p.bufferSize = bufferSize
 		p.channels = props.Channels
 		p.sampleRate = props.SampleRate
+		p.Transport.SetSampleRate(props.SampleRate)
+		// plugin reports its capabilities and pins after it's started.
 		p.plugin.Start()
+		precision, err := p.negotiatePrecision()
+		if err != nil {
+			return pipe.Processor{}, err
+		}
+		r, err := p.routing(props.Channels)
+		if err != nil {
+			return pipe.Processor{}, err
+		}
 		p.plugin.SetSampleRate(props.SampleRate)
 		p.plugin.SetBufferSize(bufferSize)
+		p.plugin.SetProcessPrecision(precision)
 		if init != nil {
 			init(p.plugin)
 		}
-		processFn, flushFn := processorFns(p.plugin, p.channels, p.bufferSize, p.progressFn)
+		processFn, flushFn := processorFns(p.plugin, precision, p.BufferPool, r, p.bufferSize, p.progressProcessed)
 		return pipe.Processor{
 			SignalProperties: pipe.SignalProperties{
-				Channels:   p.channels,
+				Channels:   r.pipeOut,
 				SampleRate: p.sampleRate,
 			},
 			StartFunc: func(context.Context) error {
@@ -75,62 +170,219 @@ func (p *Processor) Allocator(init ProcessorInitFunc) pipe.ProcessorAllocatorFun
 	}
 }
 
-func processorFns(p *Plugin, channels, bufferSize int, progressFn ProgressProcessedFunc) (pipe.ProcessFunc, pipe.FlushFunc) {
-	if p.CanProcessFloat64() {
-		return doubleFns(p, channels, bufferSize, progressFn)
+// progressProcessed advances transport and reports progress.
+func (p *Processor) progressProcessed(frames int) {
+	p.Transport.Advance(frames)
+	if p.progressFn != nil {
+		p.progressFn(frames)
 	}
-	return floatFns(p, channels, bufferSize, progressFn)
 }
 
-func doubleFns(p *Plugin, channels, bufferSize int, progressFn ProgressProcessedFunc) (pipe.ProcessFunc, pipe.FlushFunc) {
-	doubleIn := NewDoubleBuffer(channels, bufferSize)
-	doubleOut := NewDoubleBuffer(channels, bufferSize)
-	processFn := func(in, out signal.Floating) (int, error) {
-		doubleIn.Write(in)
-		p.ProcessDouble(doubleIn, doubleOut)
-		doubleOut.Read(out)
-		return in.Length(), nil
-	}
-	if progressFn != nil {
-		processFn = func(in, out signal.Floating) (int, error) {
-			doubleIn.Write(in)
-			p.ProcessDouble(doubleIn, doubleOut)
-			doubleOut.Read(out)
-			progressFn(in.Length())
-			return in.Length(), nil
+// negotiatePrecision returns precision supported by plugin. Forced
+// precision results in error if plugin doesn't support it.
+func (p *Processor) negotiatePrecision() (ProcessPrecision, error) {
+	if !p.forcePrecision {
+		if p.plugin.CanProcessFloat64() {
+			return ProcessDouble, nil
+		}
+		return ProcessFloat, nil
+	}
+	switch {
+	case p.precision == ProcessDouble && !p.plugin.CanProcessFloat64():
+		return 0, errors.New("plugin doesn't support double precision")
+	case p.precision == ProcessFloat && !p.plugin.CanProcessFloat32():
+		return 0, errors.New("plugin doesn't support float precision")
+	}
+	return p.precision, nil
+}
+
+// routing returns channel routing between pipe and plugin. Error is
+// returned if channels cannot be routed.
+func (p *Processor) routing(channels int) (routing, error) {
+	keyStart := p.plugin.NumInputs()
+	if p.Sidechain != nil {
+		keyStart = sidechainInput(p.plugin, channels)
+	}
+	return newRouting(channels, p.plugin.NumInputs(), p.plugin.NumOutputs(), keyStart, p.InputMap, p.OutputMap, p.Sidechain)
+}
+
+// newRouting returns routing of pipe channels into plugin with provided
+// number of inputs and outputs. Plugin inputs starting from keyStart
+// receive key signal if sidechain is set. If maps are not defined,
+// channels are routed into channels with the same index and extra plugin
+// inputs are padded with silence.
+func newRouting(channels, pluginIn, pluginOut, keyStart int, in, out ChannelMap, key *Sidechain) (routing, error) {
+	r := routing{
+		pipeIn:    channels,
+		pipeOut:   channels,
+		pluginIn:  pluginIn,
+		pluginOut: pluginOut,
+		in:        in,
+		out:       out,
+		keyStart:  pluginIn,
+		key:       key,
+	}
+	if r.out != nil {
+		r.pipeOut = len(r.out)
+	}
+	if r.key != nil {
+		r.keyStart = keyStart
+		keyInputs := r.pluginIn - r.keyStart
+		if keyInputs <= 0 {
+			return r, errors.New("plugin has no sidechain inputs")
+		}
+		if r.key.channels == 1 {
+			r.keyMap = DuplicateMap(1, keyInputs)
+		} else {
+			r.keyMap = IdentityMap(r.key.channels, keyInputs)
+		}
+	}
+	if r.in == nil {
+		if r.pipeIn > r.keyStart {
+			return r, fmt.Errorf("signal has %d channels, but plugin has %d main inputs", r.pipeIn, r.keyStart)
+		}
+		r.in = IdentityMap(r.pipeIn, r.keyStart)
+	}
+	if r.out == nil {
+		if r.pipeOut > r.pluginOut {
+			return r, fmt.Errorf("signal has %d channels, but plugin has %d outputs", r.pipeOut, r.pluginOut)
 		}
+		r.out = IdentityMap(r.pluginOut, r.pipeOut)
+	}
+	if err := r.in.Validate(r.pipeIn, r.keyStart); err != nil {
+		return r, fmt.Errorf("input map: %w", err)
+	}
+	if err := r.out.Validate(r.pluginOut, r.pipeOut); err != nil {
+		return r, fmt.Errorf("output map: %w", err)
+	}
+	// identity maps are not applied, signal is copied directly.
+	if r.in.isIdentity() {
+		r.in = nil
 	}
-	return processFn,
+	if r.out.isIdentity() {
+		r.out = nil
+	}
+	return r, nil
+}
+
+func processorFns(p *Plugin, precision ProcessPrecision, pool *BufferPool, r routing, bufferSize int, progressFn ProgressProcessedFunc) (pipe.ProcessFunc, pipe.FlushFunc) {
+	if precision == ProcessDouble {
+		return doubleFns(p, pool, r, bufferSize, progressFn)
+	}
+	return floatFns(p, pool, r, bufferSize, progressFn)
+}
+
+func doubleFns(p *Plugin, pool *BufferPool, r routing, bufferSize int, progressFn ProgressProcessedFunc) (pipe.ProcessFunc, pipe.FlushFunc) {
+	var stageIn, stageOut, stageKey DoubleBuffer
+	doubleIn := pool.GetDouble(r.pluginIn, bufferSize)
+	doubleOut := pool.GetDouble(r.pluginOut, bufferSize)
+	// main inputs receive pipe signal, the rest receive key signal.
+	mainIn := doubleView(doubleIn, r.keyStart, bufferSize)
+	keyIn := DoubleBuffer{Frames: bufferSize, data: doubleIn.data[r.keyStart:]}
+	if r.key != nil {
+		stageKey = pool.GetDouble(r.key.channels, bufferSize)
+	}
+	if r.in != nil {
+		stageIn = pool.GetDouble(r.pipeIn, bufferSize)
+	}
+	if r.out != nil {
+		stageOut = pool.GetDouble(r.pipeOut, bufferSize)
+	}
+	return func(in, out signal.Floating) (int, error) {
+			if r.in != nil {
+				stageIn.Write(in)
+				r.in.Double(stageIn, mainIn)
+			} else {
+				mainIn.Write(in)
+			}
+			if r.key != nil {
+				key := doubleView(stageKey, r.key.channels, in.Length())
+				r.key.readDouble(key)
+				r.keyMap.Double(key, keyIn)
+			}
+			p.ProcessDouble(doubleIn, doubleOut)
+			if r.out != nil {
+				r.out.Double(doubleOut, stageOut)
+				stageOut.Read(out)
+			} else {
+				doubleOut.Read(out)
+			}
+			if progressFn != nil {
+				progressFn(in.Length())
+			}
+			return in.Length(), nil
+		},
 		func(context.Context) error {
-			doubleIn.Free()
-			doubleOut.Free()
+			pool.PutDouble(doubleIn)
+			pool.PutDouble(doubleOut)
+			if r.in != nil {
+				pool.PutDouble(stageIn)
+			}
+			if r.out != nil {
+				pool.PutDouble(stageOut)
+			}
+			if r.key != nil {
+				pool.PutDouble(stageKey)
+				r.key.stop()
+			}
 			p.Suspend()
 			return nil
 		}
 }
 
-func floatFns(p *Plugin, channels, bufferSize int, progressFn ProgressProcessedFunc) (pipe.ProcessFunc, pipe.FlushFunc) {
-	floatIn := NewFloatBuffer(channels, bufferSize)
-	floatOut := NewFloatBuffer(channels, bufferSize)
-	processFn := func(in, out signal.Floating) (int, error) {
-		floatIn.Write(in)
-		p.ProcessFloat(floatIn, floatOut)
-		floatOut.Read(out)
-		return in.Length(), nil
-	}
-	if progressFn != nil {
-		processFn = func(in, out signal.Floating) (int, error) {
-			floatIn.Write(in)
+func floatFns(p *Plugin, pool *BufferPool, r routing, bufferSize int, progressFn ProgressProcessedFunc) (pipe.ProcessFunc, pipe.FlushFunc) {
+	var stageIn, stageOut, stageKey FloatBuffer
+	floatIn := pool.GetFloat(r.pluginIn, bufferSize)
+	floatOut := pool.GetFloat(r.pluginOut, bufferSize)
+	// main inputs receive pipe signal, the rest receive key signal.
+	mainIn := floatView(floatIn, r.keyStart, bufferSize)
+	keyIn := FloatBuffer{Frames: bufferSize, data: floatIn.data[r.keyStart:]}
+	if r.key != nil {
+		stageKey = pool.GetFloat(r.key.channels, bufferSize)
+	}
+	if r.in != nil {
+		stageIn = pool.GetFloat(r.pipeIn, bufferSize)
+	}
+	if r.out != nil {
+		stageOut = pool.GetFloat(r.pipeOut, bufferSize)
+	}
+	return func(in, out signal.Floating) (int, error) {
+			if r.in != nil {
+				stageIn.Write(in)
+				r.in.Float(stageIn, mainIn)
+			} else {
+				mainIn.Write(in)
+			}
+			if r.key != nil {
+				key := floatView(stageKey, r.key.channels, in.Length())
+				r.key.readFloat(key)
+				r.keyMap.Float(key, keyIn)
+			}
 			p.ProcessFloat(floatIn, floatOut)
-			floatOut.Read(out)
-			progressFn(in.Length())
+			if r.out != nil {
+				r.out.Float(floatOut, stageOut)
+				stageOut.Read(out)
+			} else {
+				floatOut.Read(out)
+			}
+			if progressFn != nil {
+				progressFn(in.Length())
+			}
 			return in.Length(), nil
-		}
-	}
-	return processFn,
+		},
 		func(context.Context) error {
-			floatIn.Free()
-			floatOut.Free()
+			pool.PutFloat(floatIn)
+			pool.PutFloat(floatOut)
+			if r.in != nil {
+				pool.PutFloat(stageIn)
+			}
+			if r.out != nil {
+				pool.PutFloat(stageOut)
+			}
+			if r.key != nil {
+				pool.PutFloat(stageKey)
+				r.key.stop()
+			}
 			p.Suspend()
 			return nil
 		}
@@ -0,0 +1,96 @@
+// +build !plugin
+
+package vst2
+
+import "testing"
+
+func TestRouting(t *testing.T) {
+	type maps struct {
+		in, out ChannelMap
+	}
+	testRouting := func(channels, pluginIn, pluginOut int, m maps, expected routing) func(*testing.T) {
+		return func(t *testing.T) {
+			r, err := newRouting(channels, pluginIn, pluginOut, pluginIn, m.in, m.out, nil)
+			assertEqual(t, "error", err, nil)
+			assertEqual(t, "routing", r, expected)
+		}
+	}
+	testError := func(channels, pluginIn, pluginOut int, m maps, expected string) func(*testing.T) {
+		return func(t *testing.T) {
+			_, err := newRouting(channels, pluginIn, pluginOut, pluginIn, m.in, m.out, nil)
+			if err == nil {
+				t.Fatalf("expected error: %v", expected)
+			}
+			assertEqual(t, "error", err.Error(), expected)
+		}
+	}
+	t.Run("same channels", testRouting(2, 2, 2, maps{}, routing{
+		pipeIn:    2,
+		pipeOut:   2,
+		pluginIn:  2,
+		pluginOut: 2,
+		keyStart:  2,
+	}))
+	t.Run("padding and dropping", testRouting(1, 2, 3, maps{}, routing{
+		pipeIn:    1,
+		pipeOut:   1,
+		pluginIn:  2,
+		pluginOut: 3,
+		keyStart:  2,
+		in:        IdentityMap(1, 2),
+		out:       IdentityMap(3, 1),
+	}))
+	t.Run("output map", testRouting(2, 2, 2, maps{out: DownmixMap(2, 1)}, routing{
+		pipeIn:    2,
+		pipeOut:   1,
+		pluginIn:  2,
+		pluginOut: 2,
+		keyStart:  2,
+		out:       DownmixMap(2, 1),
+	}))
+	t.Run("identity maps", testRouting(2, 2, 2, maps{IdentityMap(2, 2), IdentityMap(2, 2)}, routing{
+		pipeIn:    2,
+		pipeOut:   2,
+		pluginIn:  2,
+		pluginOut: 2,
+		keyStart:  2,
+	}))
+	t.Run("too many inputs", testError(3, 2, 3, maps{},
+		"signal has 3 channels, but plugin has 2 main inputs"))
+	t.Run("too many outputs", testError(3, 3, 2, maps{},
+		"signal has 3 channels, but plugin has 2 outputs"))
+	t.Run("invalid input map", testError(2, 2, 2, maps{in: IdentityMap(3, 2)},
+		"input map: channel map output 0 has 3 inputs, expected 2"))
+	t.Run("invalid output map", testError(2, 2, 2, maps{out: IdentityMap(3, 2)},
+		"output map: channel map output 0 has 3 inputs, expected 2"))
+
+	t.Run("mono key", func(t *testing.T) {
+		key := NewSidechain(1)
+		r, err := newRouting(2, 4, 2, 2, nil, nil, key)
+		assertEqual(t, "error", err, nil)
+		assertEqual(t, "routing", r, routing{
+			pipeIn:    2,
+			pipeOut:   2,
+			pluginIn:  4,
+			pluginOut: 2,
+			keyStart:  2,
+			key:       key,
+			keyMap:    DuplicateMap(1, 2),
+		})
+	})
+	t.Run("stereo key", func(t *testing.T) {
+		key := NewSidechain(2)
+		r, err := newRouting(1, 4, 2, 2, nil, nil, key)
+		assertEqual(t, "error", err, nil)
+		assertEqual(t, "main inputs", r.in, IdentityMap(1, 2))
+		assertEqual(t, "key map", r.keyMap, IdentityMap(2, 2))
+	})
+	t.Run("no key inputs", func(t *testing.T) {
+		_, err := newRouting(2, 2, 2, 2, nil, nil, NewSidechain(1))
+		assertEqual(t, "error", err.Error(), "plugin has no sidechain inputs")
+	})
+	t.Run("key inputs overlap signal", func(t *testing.T) {
+		_, err := newRouting(2, 3, 2, 1, nil, nil, NewSidechain(1))
+		assertEqual(t, "error", err.Error(), "signal has 2 channels, but plugin has 1 main inputs")
+	})
+}
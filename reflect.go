@@ -0,0 +1,256 @@
+package vst2
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const parameterTag = "vst2"
+
+// ParameterStruct declares plugin parameters with tagged struct fields.
+// Supported field types are floats, integers and bool. Tag is a comma
+// separated list of options:
+//
+//	name        parameter name, field name is used by default
+//	unit        parameter unit label
+//	min, max    range of plain value, 0 and 1 by default
+//	default     default plain value, min by default
+//	curve       mapping of normalized value: lin (default) or log
+//	step        step of plain value, integers use step 1 by default
+//	automatable false if parameter cannot be automated
+//
+// Fields tagged with "-" are ignored. Example:
+//
+//	type params struct {
+//		Gain   float64 `vst2:"unit=dB,min=-20,max=20,default=0"`
+//		Cutoff float32 `vst2:"unit=Hz,min=20,max=20000,curve=log"`
+//		Mode   int     `vst2:"min=0,max=3,automatable=false"`
+//		Bypass bool
+//	}
+type ParameterStruct struct {
+	Parameters []*Parameter
+	fields     []reflect.Value
+	mappings   []parameterField
+}
+
+// parameterField describes the mapping between normalized parameter
+// value and the struct field.
+type parameterField struct {
+	kind     reflect.Kind
+	min, max float64
+	log      bool
+	step     float64
+}
+
+// NewParameterStruct generates parameters from provided pointer to struct.
+// Struct fields are not updated automatically, call Update in processing
+// routine to copy current values into the struct.
+func NewParameterStruct(v interface{}) (*ParameterStruct, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("expected pointer to struct, got: %T", v)
+	}
+	rv = rv.Elem()
+	var ps ParameterStruct
+	// names are keys of chunk values, so they must be unique.
+	names := make(map[string]string)
+	for i := 0; i < rv.NumField(); i++ {
+		sf := rv.Type().Field(i)
+		tag := sf.Tag.Get(parameterTag)
+		if tag == "-" || sf.PkgPath != "" {
+			continue
+		}
+		p, f, err := newStructParameter(sf, tag)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", sf.Name, err)
+		}
+		if field, ok := names[p.Name]; ok {
+			return nil, fmt.Errorf("field %s: parameter name %q is already used by field %s", sf.Name, p.Name, field)
+		}
+		names[p.Name] = sf.Name
+		ps.Parameters = append(ps.Parameters, p)
+		ps.fields = append(ps.fields, rv.Field(i))
+		ps.mappings = append(ps.mappings, f)
+	}
+	ps.Update()
+	return &ps, nil
+}
+
+func newStructParameter(sf reflect.StructField, tag string) (*Parameter, parameterField, error) {
+	f := parameterField{
+		kind: sf.Type.Kind(),
+		max:  1,
+	}
+	switch f.kind {
+	case reflect.Float32, reflect.Float64, reflect.Bool:
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f.step = 1
+	default:
+		return nil, f, fmt.Errorf("unsupported type: %v", sf.Type)
+	}
+	p := Parameter{
+		Name: sf.Name,
+	}
+	var (
+		def    float64
+		hasDef bool
+	)
+	if tag != "" {
+		for _, opt := range strings.Split(tag, ",") {
+			kv := strings.SplitN(opt, "=", 2)
+			if len(kv) != 2 {
+				return nil, f, fmt.Errorf("invalid option: %q", opt)
+			}
+			key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+			var err error
+			switch key {
+			case "name":
+				p.Name = value
+			case "unit":
+				p.Unit = value
+			case "min":
+				f.min, err = strconv.ParseFloat(value, 64)
+			case "max":
+				f.max, err = strconv.ParseFloat(value, 64)
+			case "default":
+				def, err = strconv.ParseFloat(value, 64)
+				hasDef = true
+			case "step":
+				f.step, err = strconv.ParseFloat(value, 64)
+			case "curve":
+				switch value {
+				case "lin":
+				case "log":
+					f.log = true
+				default:
+					err = fmt.Errorf("unknown curve: %q", value)
+				}
+			case "automatable":
+				var automatable bool
+				automatable, err = strconv.ParseBool(value)
+				p.NotAutomated = !automatable
+			default:
+				err = fmt.Errorf("unknown option: %q", key)
+			}
+			if err != nil {
+				return nil, f, err
+			}
+		}
+	}
+	if f.min >= f.max {
+		return nil, f, fmt.Errorf("min %v must be less than max %v", f.min, f.max)
+	}
+	if f.log && f.min <= 0 {
+		return nil, f, fmt.Errorf("log curve requires positive min: %v", f.min)
+	}
+	if !hasDef {
+		def = f.min
+	}
+
+	switch f.kind {
+	case reflect.Bool:
+		p.IsSwitch = true
+		f.min, f.max, f.step = 0, 1, 1
+	case reflect.Float32, reflect.Float64:
+		p.StepFloat = float32(f.step)
+	default:
+		p.MinInteger = int(f.min)
+		p.MaxInteger = int(f.max)
+		p.StepInteger = int(f.step)
+	}
+	p.Default = f.normalize(def)
+	p.GetValueFunc = func(value float32) float32 {
+		return float32(f.plain(value))
+	}
+	p.GetValueLabelFunc = f.label
+	p.SetValue(p.Default)
+	return &p, f, nil
+}
+
+// plain maps normalized value into the plain value.
+func (f parameterField) plain(value float32) float64 {
+	n := math.Min(math.Max(float64(value), 0), 1)
+	var v float64
+	if f.log {
+		v = f.min * math.Pow(f.max/f.min, n)
+	} else {
+		v = f.min + n*(f.max-f.min)
+	}
+	if f.step > 0 {
+		v = f.min + math.Round((v-f.min)/f.step)*f.step
+	}
+	return math.Min(math.Max(v, f.min), f.max)
+}
+
+// normalize maps plain value into the normalized value.
+func (f parameterField) normalize(v float64) float32 {
+	v = math.Min(math.Max(v, f.min), f.max)
+	if f.log {
+		return float32(math.Log(v/f.min) / math.Log(f.max/f.min))
+	}
+	return float32((v - f.min) / (f.max - f.min))
+}
+
+// label formats plain value.
+func (f parameterField) label(v float32) string {
+	switch f.kind {
+	case reflect.Bool:
+		if v >= 0.5 {
+			return "On"
+		}
+		return "Off"
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(float64(v), 'f', 2, 32)
+	default:
+		return strconv.Itoa(int(v))
+	}
+}
+
+// Update copies current parameter values into the struct fields. It
+// should be called in the processing routine before struct is used.
+func (ps *ParameterStruct) Update() {
+	for i, p := range ps.Parameters {
+		// plain value is computed with double precision for float64
+		// fields.
+		v := ps.mappings[i].plain(p.Value())
+		switch f := ps.fields[i]; f.Kind() {
+		case reflect.Bool:
+			f.SetBool(v >= 0.5)
+		case reflect.Float32, reflect.Float64:
+			f.SetFloat(v)
+		default:
+			f.SetInt(int64(math.Round(v)))
+		}
+	}
+}
+
+// GetChunk returns plain parameter values keyed by parameter names. Such
+// chunks can be loaded after parameters are added, removed or reordered.
+// It can be used as Dispatcher.GetChunkFunc.
+func (ps *ParameterStruct) GetChunk(isPreset bool) []byte {
+	values := make(map[string]float64, len(ps.Parameters))
+	for i, p := range ps.Parameters {
+		values[p.Name] = ps.mappings[i].plain(p.Value())
+	}
+	data, _ := json.Marshal(values)
+	return data
+}
+
+// SetChunk sets parameter values from the chunk created by GetChunk.
+// Unknown parameters are ignored. It can be used as
+// Dispatcher.SetChunkFunc.
+func (ps *ParameterStruct) SetChunk(data []byte, isPreset bool) {
+	var values map[string]float64
+	if err := json.Unmarshal(data, &values); err != nil {
+		return
+	}
+	for i, p := range ps.Parameters {
+		if v, ok := values[p.Name]; ok {
+			p.SetValue(ps.mappings[i].normalize(v))
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package vst2_test
+
+import (
+	"math"
+	"testing"
+
+	"pipelined.dev/audio/vst2"
+)
+
+func TestParameterStruct(t *testing.T) {
+	var params struct {
+		Gain   float64 `vst2:"unit=dB,min=-20,max=20,default=0"`
+		Cutoff float64 `vst2:"name=Freq,unit=Hz,min=20,max=20000,curve=log,default=20000"`
+		Mode   int     `vst2:"min=0,max=3,automatable=false"`
+		Bypass bool
+		Ignore int `vst2:"-"`
+	}
+	ps, err := vst2.NewParameterStruct(&params)
+	assertEqual(t, "error", err, nil)
+	assertEqual(t, "num parameters", len(ps.Parameters), 4)
+
+	gain, cutoff, mode, bypass := ps.Parameters[0], ps.Parameters[1], ps.Parameters[2], ps.Parameters[3]
+	assertEqual(t, "gain default", gain.Default, float32(0.5))
+	assertEqual(t, "gain unit", gain.Unit, "dB")
+	assertEqual(t, "cutoff name", cutoff.Name, "Freq")
+	assertEqual(t, "cutoff default", cutoff.Default, float32(1))
+	assertEqual(t, "mode not automated", mode.NotAutomated, true)
+	assertEqual(t, "mode max", mode.MaxInteger, 3)
+	assertEqual(t, "bypass switch", bypass.IsSwitch, true)
+	assertEqual(t, "initial gain", params.Gain, float64(0))
+	assertEqual(t, "initial cutoff", params.Cutoff, float64(20000))
+
+	gain.SetValue(1)
+	cutoff.SetValue(0.5)
+	mode.SetValue(0.7)
+	bypass.SetValue(1)
+	ps.Update()
+	assertEqual(t, "gain", params.Gain, float64(20))
+	assertEqual(t, "cutoff", params.Cutoff, 20*math.Sqrt(1000))
+	assertEqual(t, "mode", params.Mode, 2)
+	assertEqual(t, "bypass", params.Bypass, true)
+	assertEqual(t, "gain label", gain.GetValueLabel(), "20.00")
+	assertEqual(t, "bypass label", bypass.GetValueLabel(), "On")
+
+	chunk := ps.GetChunk(true)
+	gain.SetValue(0)
+	mode.SetValue(0)
+	ps.SetChunk(chunk, true)
+	ps.Update()
+	assertEqual(t, "gain after chunk", params.Gain, float64(20))
+	assertEqual(t, "mode after chunk", params.Mode, 2)
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := vst2.NewParameterStruct(params)
+		assertEqual(t, "not pointer", err != nil, true)
+		_, err = vst2.NewParameterStruct(&struct {
+			Name string
+		}{})
+		assertEqual(t, "unsupported type", err != nil, true)
+		_, err = vst2.NewParameterStruct(&struct {
+			Gain float64 `vst2:"min=1,max=0"`
+		}{})
+		assertEqual(t, "invalid range", err != nil, true)
+		_, err = vst2.NewParameterStruct(&struct {
+			Gain  float64
+			Level float64 `vst2:"name=Gain"`
+		}{})
+		assertEqual(t, "duplicate name", err != nil, true)
+	})
+}
@@ -0,0 +1,256 @@
+// +build !plugin
+
+package vst2
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+// sidechainBlocks is a number of key signal blocks that can be buffered
+// before key line is blocked.
+const sidechainBlocks = 4
+
+// Sidechain passes key signal from another line to the processor. Key
+// line must end with the Sink and processor must have Sidechain set. Key
+// signal is aligned with processor input block by block, silence is used
+// when key line is finished.
+type Sidechain struct {
+	channels int
+	mu       sync.Mutex
+	blocks   chan [][]float64
+	free     chan [][]float64
+	done     chan struct{}
+	stopOnce *sync.Once
+	// current block and number of frames read from it.
+	current [][]float64
+	offset  int
+}
+
+// NewSidechain returns sidechain for key signal with provided number of
+// channels.
+func NewSidechain(channels int) *Sidechain {
+	s := Sidechain{channels: channels}
+	s.reset()
+	return &s
+}
+
+// reset prepares sidechain for a new run.
+func (s *Sidechain) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blocks = make(chan [][]float64, sidechainBlocks)
+	s.free = make(chan [][]float64, sidechainBlocks+1)
+	s.done = make(chan struct{})
+	s.stopOnce = &sync.Once{}
+	s.current, s.offset = nil, 0
+}
+
+// channelsState returns channels of the current run.
+func (s *Sidechain) channelsState() (blocks, free chan [][]float64, done chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.blocks, s.free, s.done
+}
+
+// stop releases the key line if processor doesn't read it anymore.
+func (s *Sidechain) stop() {
+	s.mu.Lock()
+	once, done := s.stopOnce, s.done
+	s.mu.Unlock()
+	once.Do(func() { close(done) })
+}
+
+// Sink returns sink allocator that passes key signal to the processor.
+func (s *Sidechain) Sink() pipe.SinkAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Sink, error) {
+		if props.Channels != s.channels {
+			return pipe.Sink{}, fmt.Errorf("key signal has %d channels, sidechain expects %d", props.Channels, s.channels)
+		}
+		s.reset()
+		blocks, free, done := s.channelsState()
+		return pipe.Sink{
+			SinkFunc: func(in signal.Floating) error {
+				var block [][]float64
+				select {
+				case block = <-free:
+				default:
+					block = make([][]float64, s.channels)
+				}
+				for c := range block {
+					if cap(block[c]) < in.Length() {
+						block[c] = make([]float64, in.Length())
+					}
+					block[c] = block[c][:in.Length()]
+				}
+				signal.ReadStripedFloat64(in, block)
+				select {
+				case blocks <- block:
+				case <-done:
+				}
+				return nil
+			},
+			FlushFunc: func(context.Context) error {
+				close(blocks)
+				return nil
+			},
+		}, nil
+	}
+}
+
+// next returns key block with unread frames, nil is returned if key line
+// is finished.
+func (s *Sidechain) next(blocks, free chan [][]float64) [][]float64 {
+	if s.current != nil && s.offset < len(s.current[0]) {
+		return s.current
+	}
+	if s.current != nil {
+		select {
+		case free <- s.current:
+		default:
+		}
+	}
+	s.current, s.offset = <-blocks, 0
+	return s.current
+}
+
+// readDouble fills buffer with key signal.
+func (s *Sidechain) readDouble(b DoubleBuffer) {
+	blocks, free, _ := s.channelsState()
+	for filled := 0; filled < b.Frames; {
+		block := s.next(blocks, free)
+		if block == nil {
+			b.zeroRange(filled, b.Frames)
+			return
+		}
+		n := min(b.Frames-filled, len(block[0])-s.offset)
+		for c := range b.data {
+			copy(b.Channel(c)[filled:filled+n], block[c][s.offset:s.offset+n])
+		}
+		filled += n
+		s.offset += n
+	}
+}
+
+// readFloat fills buffer with key signal.
+func (s *Sidechain) readFloat(b FloatBuffer) {
+	blocks, free, _ := s.channelsState()
+	for filled := 0; filled < b.Frames; {
+		block := s.next(blocks, free)
+		if block == nil {
+			b.zeroRange(filled, b.Frames)
+			return
+		}
+		n := min(b.Frames-filled, len(block[0])-s.offset)
+		for c := range b.data {
+			dst, src := b.Channel(c)[filled:filled+n], block[c][s.offset:s.offset+n]
+			for i := range dst {
+				dst[i] = float32(src[i])
+			}
+		}
+		filled += n
+		s.offset += n
+	}
+}
+
+// sidechainInput returns index of the first sidechain input of the plugin.
+func sidechainInput(p *Plugin, mainChannels int) int {
+	pins := make([]*PinProperties, p.NumInputs())
+	for i := range pins {
+		if props, ok := p.InputProperties(i); ok {
+			pins[i] = props
+		}
+	}
+	return sidechainStart(pins, mainChannels)
+}
+
+// sidechainStart returns index of the first sidechain input for provided
+// input pins properties, nil properties mean that plugin doesn't provide
+// them. Inputs that follow main signal channels are searched for labels
+// marked as sidechain. If there are no such labels, stereo pairing of
+// pins is used: main bus ends on the first pair boundary that fits main
+// signal channels. If plugin doesn't report stereo pairs, the upper half
+// of inputs is used for key signal.
+func sidechainStart(pins []*PinProperties, mainChannels int) int {
+	numInputs := len(pins)
+	if mainChannels >= numInputs {
+		return numInputs
+	}
+	for i := mainChannels; i < numInputs; i++ {
+		if pins[i] == nil {
+			break
+		}
+		if isSidechainLabel(pins[i].Label.String()) {
+			return i
+		}
+	}
+	if start, ok := stereoBoundary(pins, mainChannels); ok {
+		return start
+	}
+	return maxInt(mainChannels, numInputs-numInputs/2)
+}
+
+// stereoBoundary returns the first boundary of stereo pairs that isn't
+// less than provided number of channels. Boolean result is false if
+// properties of some pins are not provided or there are no stereo pairs.
+func stereoBoundary(pins []*PinProperties, channels int) (int, bool) {
+	var stereo bool
+	boundary := -1
+	for i := 0; i < len(pins); {
+		if pins[i] == nil {
+			return 0, false
+		}
+		if pins[i].Flags&PinIsStereo == PinIsStereo {
+			stereo = true
+			i += 2
+		} else {
+			i++
+		}
+		if boundary < 0 && i >= maxInt(channels, 1) {
+			boundary = min(i, len(pins))
+		}
+	}
+	return boundary, stereo && boundary >= 0
+}
+
+// isSidechainLabel returns true if label contains one of the words that
+// mark sidechain pins, e.g. "Sidechain L" or "Key In".
+func isSidechainLabel(label string) bool {
+	words := strings.FieldsFunc(strings.ToLower(label), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, w := range words {
+		switch w {
+		case "side", "sidechain", "key", "sc":
+			return true
+		}
+	}
+	return false
+}
+
+// zeroRange sets samples in frames [from, to) to zero.
+func (b DoubleBuffer) zeroRange(from, to int) {
+	for c := range b.data {
+		s := b.Channel(c)[from:to]
+		for i := range s {
+			s[i] = 0
+		}
+	}
+}
+
+// zeroRange sets samples in frames [from, to) to zero.
+func (b FloatBuffer) zeroRange(from, to int) {
+	for c := range b.data {
+		s := b.Channel(c)[from:to]
+		for i := range s {
+			s[i] = 0
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// +build !plugin
+
+package vst2
+
+import (
+	"context"
+	"testing"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+func TestSidechain(t *testing.T) {
+	s := NewSidechain(2)
+	sink, err := s.Sink()(mutable.Context{}, 3, pipe.SignalProperties{Channels: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	write := func(key [][]float64) {
+		f := signal.Allocator{
+			Channels: 2,
+			Length:   len(key[0]),
+			Capacity: len(key[0]),
+		}.Float64()
+		signal.WriteStripedFloat64(key, f)
+		if err := sink.SinkFunc(f); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	write([][]float64{{1, 2, 3}, {4, 5, 6}})
+	write([][]float64{{7}, {8}})
+	sink.FlushFunc(context.Background())
+
+	b := NewDoubleBuffer(2, 2)
+	defer b.Free()
+	s.readDouble(b)
+	assertEqual(t, "first block", b.Channel(0), []float64{1, 2})
+	s.readDouble(b)
+	assertEqual(t, "spanning blocks", b.Channel(1), []float64{6, 8})
+
+	f := NewFloatBuffer(2, 2)
+	defer f.Free()
+	s.readFloat(f)
+	assertEqual(t, "silence after flush", f.Channel(0), []float32{0, 0})
+
+	_, err = s.Sink()(mutable.Context{}, 3, pipe.SignalProperties{Channels: 1})
+	assertEqual(t, "channels mismatch", err != nil, true)
+}
+
+func TestSidechainLabel(t *testing.T) {
+	labels := map[string]bool{
+		"Sidechain L":  true,
+		"Side Chain 1": true,
+		"Key In":       true,
+		"SC-R":         true,
+		"sidechain2":   true,
+		"Input L":      false,
+		"Keyboard":     false,
+		"Outside":      false,
+		"Monkey":       false,
+	}
+	for label, expected := range labels {
+		assertEqual(t, label, isSidechainLabel(label), expected)
+	}
+}
+
+func TestSidechainStart(t *testing.T) {
+	pin := func(label string, flags PinPropertiesFlag) *PinProperties {
+		var props PinProperties
+		copyASCII(props.Label[:], label)
+		props.Flags = flags
+		return &props
+	}
+	stereo := []*PinProperties{
+		pin("In L", PinIsStereo),
+		pin("In R", 0),
+		pin("Aux L", PinIsStereo),
+		pin("Aux R", 0),
+	}
+	tests := []struct {
+		name         string
+		pins         []*PinProperties
+		mainChannels int
+		expected     int
+	}{
+		{"label", []*PinProperties{pin("In", 0), pin("Aux", 0), pin("Key", 0)}, 1, 2},
+		{"stereo pairs mono main", stereo, 1, 2},
+		{"stereo pairs stereo main", stereo, 2, 2},
+		{"upper half mono main", []*PinProperties{nil, nil, nil, nil}, 1, 2},
+		{"upper half stereo main", []*PinProperties{nil, nil, nil}, 2, 2},
+		{"mono pins", []*PinProperties{pin("1", 0), pin("2", 0), pin("3", 0), pin("4", 0)}, 1, 2},
+		{"no key inputs", stereo[:2], 2, 2},
+	}
+	for _, test := range tests {
+		assertEqual(t, test.name, sidechainStart(test.pins, test.mainChannels), test.expected)
+	}
+}
@@ -0,0 +1,315 @@
+// +build !plugin
+
+package vst2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+// splitterBlocks is a number of blocks that can be buffered for every
+// output before instrument line is blocked.
+const splitterBlocks = 4
+
+type (
+	// Splitter is pipe component that runs multi-output plugin, e.g.
+	// drum sampler, and passes groups of its outputs into separate lines.
+	// Instrument line ends with the Sink and every output line starts
+	// with the Source of the group. Instrument line must be passed to the
+	// pipe before output lines. Outputs without lines are dropped.
+	Splitter struct {
+		plugin *Plugin
+		// explicit groups are provided by user, plugin groups are used
+		// otherwise.
+		explicit []OutputGroup
+		mu       sync.Mutex
+		// set when instrument line is allocated.
+		groups     []OutputGroup
+		sampleRate signal.Frequency
+		allocated  bool
+		outputs    []*splitterOutput
+		// BufferPool is optional, if set then processing buffers are
+		// taken from it and released into it when splitter is flushed.
+		BufferPool *BufferPool
+	}
+
+	// OutputGroup is a group of plugin outputs that is passed into a
+	// single line.
+	OutputGroup struct {
+		Label    string
+		Channels []int
+	}
+
+	// splitterOutput passes blocks of a single group into output line.
+	splitterOutput struct {
+		blocks   chan [][]float64
+		free     chan [][]float64
+		done     chan struct{}
+		stopOnce sync.Once
+	}
+)
+
+// OutputGroups returns plugin outputs grouped by pin properties. Pin
+// that is first of a stereo pair forms a group with the next pin, other
+// pins form mono groups. Pin labels are used as group labels. If plugin
+// doesn't provide pin properties, every output forms mono group.
+func (p *Plugin) OutputGroups() []OutputGroup {
+	var groups []OutputGroup
+	for i := 0; i < p.NumOutputs(); i++ {
+		props, ok := p.OutputProperties(i)
+		if !ok {
+			groups = append(groups, OutputGroup{
+				Label:    fmt.Sprintf("Output %d", i+1),
+				Channels: []int{i},
+			})
+			continue
+		}
+		g := OutputGroup{
+			Label:    props.Label.String(),
+			Channels: []int{i},
+		}
+		if props.Flags&PinIsStereo == PinIsStereo && i+1 < p.NumOutputs() {
+			i++
+			g.Channels = append(g.Channels, i)
+		}
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+// NewSplitter returns splitter for provided plugin. If groups are not
+// provided, plugin output groups are used. They are resolved when
+// instrument line is allocated, because plugin reports its pins after
+// it's started.
+func NewSplitter(p *Plugin, groups ...OutputGroup) *Splitter {
+	return &Splitter{
+		plugin:   p,
+		explicit: groups,
+	}
+}
+
+// Groups returns output groups of the splitter. Index of the group is
+// used to get its source. If groups were not provided, nil is returned
+// until instrument line is allocated.
+func (s *Splitter) Groups() []OutputGroup {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.allocated {
+		return s.explicit
+	}
+	return s.groups
+}
+
+// Sink returns sink allocator for instrument line. Signal of the line is
+// passed into plugin inputs, if plugin has any. Init function is applied
+// to plugin before it's started.
+func (s *Splitter) Sink(init ProcessorInitFunc) pipe.SinkAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Sink, error) {
+		// plugin reports its capabilities and pins after it's started.
+		s.plugin.Start()
+		groups := s.explicit
+		if len(groups) == 0 {
+			groups = s.plugin.OutputGroups()
+		}
+		for _, g := range groups {
+			for _, c := range g.Channels {
+				if c < 0 || c >= s.plugin.NumOutputs() {
+					return pipe.Sink{}, fmt.Errorf("group %q: plugin has no output %d", g.Label, c)
+				}
+			}
+		}
+		s.mu.Lock()
+		s.groups = groups
+		s.sampleRate = props.SampleRate
+		s.allocated = true
+		s.outputs = make([]*splitterOutput, len(groups))
+		s.mu.Unlock()
+
+		precision := ProcessDouble
+		if !s.plugin.CanProcessFloat64() {
+			precision = ProcessFloat
+		}
+		s.plugin.SetSampleRate(props.SampleRate)
+		s.plugin.SetBufferSize(bufferSize)
+		s.plugin.SetProcessPrecision(precision)
+		if init != nil {
+			init(s.plugin)
+		}
+		// outputs are connected when output lines are allocated.
+		var outputs []*splitterOutput
+		startFn := func(context.Context) error {
+			s.mu.Lock()
+			outputs = append(outputs[:0], s.outputs...)
+			s.mu.Unlock()
+			s.plugin.Resume()
+			return nil
+		}
+		var (
+			sinkFn  pipe.SinkFunc
+			flushFn pipe.FlushFunc
+		)
+		if precision == ProcessDouble {
+			sinkFn, flushFn = s.doubleFns(props.Channels, bufferSize, groups, &outputs)
+		} else {
+			sinkFn, flushFn = s.floatFns(props.Channels, bufferSize, groups, &outputs)
+		}
+		return pipe.Sink{
+			StartFunc: startFn,
+			SinkFunc:  sinkFn,
+			FlushFunc: flushFn,
+		}, nil
+	}
+}
+
+// Source returns source allocator for output line of the group with
+// provided index.
+func (s *Splitter) Source(group int) pipe.SourceAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int) (pipe.Source, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if !s.allocated {
+			return pipe.Source{}, errors.New("instrument line must be allocated before output lines")
+		}
+		if group < 0 || group >= len(s.groups) {
+			return pipe.Source{}, fmt.Errorf("splitter has no group %d", group)
+		}
+		if s.outputs[group] != nil {
+			return pipe.Source{}, fmt.Errorf("group %q is already connected", s.groups[group].Label)
+		}
+		o := &splitterOutput{
+			blocks: make(chan [][]float64, splitterBlocks),
+			free:   make(chan [][]float64, splitterBlocks+1),
+			done:   make(chan struct{}),
+		}
+		s.outputs[group] = o
+		return pipe.Source{
+			SignalProperties: pipe.SignalProperties{
+				Channels:   len(s.groups[group].Channels),
+				SampleRate: s.sampleRate,
+			},
+			SourceFunc: func(out signal.Floating) (int, error) {
+				block, ok := <-o.blocks
+				if !ok {
+					return 0, io.EOF
+				}
+				n := signal.WriteStripedFloat64(block, out)
+				select {
+				case o.free <- block:
+				default:
+				}
+				return n, nil
+			},
+			FlushFunc: func(context.Context) error {
+				o.stop()
+				return nil
+			},
+		}, nil
+	}
+}
+
+// push sends copy of the group channels into output line. Samples are
+// returned by channel function.
+func (o *splitterOutput) push(g OutputGroup, frames int, channel func(c int, dst []float64)) {
+	var block [][]float64
+	select {
+	case block = <-o.free:
+	default:
+		block = make([][]float64, len(g.Channels))
+	}
+	for i, c := range g.Channels {
+		if cap(block[i]) < frames {
+			block[i] = make([]float64, frames)
+		}
+		block[i] = block[i][:frames]
+		channel(c, block[i])
+	}
+	select {
+	case o.blocks <- block:
+	case <-o.done:
+	}
+}
+
+// stop releases the instrument line if output line doesn't read anymore.
+func (o *splitterOutput) stop() {
+	o.stopOnce.Do(func() { close(o.done) })
+}
+
+// closeOutputs signals output lines that instrument line is finished.
+func closeOutputs(outputs []*splitterOutput) {
+	for _, o := range outputs {
+		if o != nil {
+			close(o.blocks)
+		}
+	}
+}
+
+func (s *Splitter) doubleFns(channels, bufferSize int, groups []OutputGroup, outputs *[]*splitterOutput) (pipe.SinkFunc, pipe.FlushFunc) {
+	pool := s.BufferPool
+	stageIn := pool.GetDouble(channels, bufferSize)
+	doubleIn := pool.GetDouble(s.plugin.NumInputs(), bufferSize)
+	doubleOut := pool.GetDouble(s.plugin.NumOutputs(), bufferSize)
+	inputMap := IdentityMap(channels, s.plugin.NumInputs())
+	return func(in signal.Floating) error {
+			frames := in.Length()
+			stageIn.Write(in)
+			inputMap.Double(stageIn, doubleIn)
+			s.plugin.ProcessDouble(doubleIn, doubleOut)
+			for i, o := range *outputs {
+				if o == nil {
+					continue
+				}
+				o.push(groups[i], frames, func(c int, dst []float64) {
+					copy(dst, doubleOut.Channel(c))
+				})
+			}
+			return nil
+		},
+		func(context.Context) error {
+			s.plugin.Suspend()
+			closeOutputs(*outputs)
+			pool.PutDouble(stageIn)
+			pool.PutDouble(doubleIn)
+			pool.PutDouble(doubleOut)
+			return nil
+		}
+}
+
+func (s *Splitter) floatFns(channels, bufferSize int, groups []OutputGroup, outputs *[]*splitterOutput) (pipe.SinkFunc, pipe.FlushFunc) {
+	pool := s.BufferPool
+	stageIn := pool.GetFloat(channels, bufferSize)
+	floatIn := pool.GetFloat(s.plugin.NumInputs(), bufferSize)
+	floatOut := pool.GetFloat(s.plugin.NumOutputs(), bufferSize)
+	inputMap := IdentityMap(channels, s.plugin.NumInputs())
+	return func(in signal.Floating) error {
+			frames := in.Length()
+			stageIn.Write(in)
+			inputMap.Float(stageIn, floatIn)
+			s.plugin.ProcessFloat(floatIn, floatOut)
+			for i, o := range *outputs {
+				if o == nil {
+					continue
+				}
+				o.push(groups[i], frames, func(c int, dst []float64) {
+					for j, v := range floatOut.Channel(c)[:len(dst)] {
+						dst[j] = float64(v)
+					}
+				})
+			}
+			return nil
+		},
+		func(context.Context) error {
+			s.plugin.Suspend()
+			closeOutputs(*outputs)
+			pool.PutFloat(stageIn)
+			pool.PutFloat(floatIn)
+			pool.PutFloat(floatOut)
+			return nil
+		}
+}
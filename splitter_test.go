@@ -0,0 +1,48 @@
+// +build !plugin
+
+package vst2
+
+import (
+	"io"
+	"testing"
+
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+func TestSplitterSource(t *testing.T) {
+	s := &Splitter{
+		groups: []OutputGroup{
+			{Label: "Kick", Channels: []int{0}},
+			{Label: "Overheads", Channels: []int{2, 3}},
+		},
+		outputs: make([]*splitterOutput, 2),
+	}
+	_, err := s.Source(1)(mutable.Context{}, 2)
+	assertEqual(t, "not allocated", err != nil, true)
+
+	s.allocated = true
+	source, err := s.Source(1)(mutable.Context{}, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertEqual(t, "channels", source.SignalProperties.Channels, 2)
+	_, err = s.Source(1)(mutable.Context{}, 2)
+	assertEqual(t, "connected twice", err != nil, true)
+
+	outputs := []*splitterOutput{nil, s.outputs[1]}
+	plugin := [][]float64{{1, 2}, {3, 4}, {5, 6}, {7, 8}}
+	s.outputs[1].push(s.groups[1], 2, func(c int, dst []float64) {
+		copy(dst, plugin[c])
+	})
+	closeOutputs(outputs)
+
+	out := signal.Allocator{Channels: 2, Length: 2, Capacity: 2}.Float64()
+	n, err := source.SourceFunc(out)
+	assertEqual(t, "read", n, 2)
+	result := [][]float64{make([]float64, 2), make([]float64, 2)}
+	signal.ReadStripedFloat64(out, result)
+	assertEqual(t, "overheads", result, [][]float64{{5, 6}, {7, 8}})
+	_, err = source.SourceFunc(out)
+	assertEqual(t, "finished", err, io.EOF)
+}
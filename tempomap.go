@@ -0,0 +1,256 @@
+package vst2
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"pipelined.dev/signal"
+)
+
+type (
+	// TempoMap defines tempo and time signature changes over musical
+	// position. It converts time to musical position and back. Tempo map
+	// always has a tempo point and a time signature at the beginning.
+	// TempoMap is safe for concurrent use.
+	TempoMap struct {
+		mu         sync.RWMutex
+		tempos     []TempoPoint
+		signatures []TimeSignature
+	}
+
+	// TempoPoint sets tempo in BPM at position in quarter notes. If Ramp
+	// is set, tempo changes linearly from the previous point to this one,
+	// otherwise it changes instantly.
+	TempoPoint struct {
+		Position float64
+		Tempo    float64
+		Ramp     bool
+	}
+
+	// TimeSignature sets time signature starting from the bar. Bars are
+	// counted from zero.
+	TimeSignature struct {
+		Bar         int
+		Numerator   int
+		Denominator int
+	}
+)
+
+// NewTempoMap returns tempo map with initial tempo in BPM and time
+// signature.
+func NewTempoMap(tempo float64, numerator, denominator int) *TempoMap {
+	return &TempoMap{
+		tempos: []TempoPoint{{Tempo: tempo}},
+		signatures: []TimeSignature{{
+			Numerator:   numerator,
+			Denominator: denominator,
+		}},
+	}
+}
+
+// SetTempo changes tempo instantly at position in quarter notes.
+func (m *TempoMap) SetTempo(position, tempo float64) {
+	m.setTempo(TempoPoint{Position: position, Tempo: tempo})
+}
+
+// RampTempo changes tempo linearly from the previous tempo point to reach
+// provided tempo at position in quarter notes.
+func (m *TempoMap) RampTempo(position, tempo float64) {
+	m.setTempo(TempoPoint{Position: position, Tempo: tempo, Ramp: true})
+}
+
+func (m *TempoMap) setTempo(p TempoPoint) {
+	if p.Position <= 0 {
+		p.Position, p.Ramp = 0, false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := sort.Search(len(m.tempos), func(i int) bool {
+		return m.tempos[i].Position >= p.Position
+	})
+	if i < len(m.tempos) && m.tempos[i].Position == p.Position {
+		m.tempos[i] = p
+		return
+	}
+	m.tempos = append(m.tempos, TempoPoint{})
+	copy(m.tempos[i+1:], m.tempos[i:])
+	m.tempos[i] = p
+}
+
+// SetTimeSignature changes time signature starting from provided bar.
+func (m *TempoMap) SetTimeSignature(bar, numerator, denominator int) {
+	if bar < 0 {
+		bar = 0
+	}
+	s := TimeSignature{Bar: bar, Numerator: numerator, Denominator: denominator}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := sort.Search(len(m.signatures), func(i int) bool {
+		return m.signatures[i].Bar >= bar
+	})
+	if i < len(m.signatures) && m.signatures[i].Bar == bar {
+		m.signatures[i] = s
+		return
+	}
+	m.signatures = append(m.signatures, TimeSignature{})
+	copy(m.signatures[i+1:], m.signatures[i:])
+	m.signatures[i] = s
+}
+
+// TempoPoints returns tempo points ordered by position.
+func (m *TempoMap) TempoPoints() []TempoPoint {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]TempoPoint(nil), m.tempos...)
+}
+
+// TimeSignatures returns time signatures ordered by bar.
+func (m *TempoMap) TimeSignatures() []TimeSignature {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]TimeSignature(nil), m.signatures...)
+}
+
+// TempoAt returns tempo in BPM at position in quarter notes.
+func (m *TempoMap) TempoAt(ppq float64) float64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	i := m.segment(ppq)
+	tempo, slope, _ := m.segmentTempo(i)
+	return tempo + slope*(ppq-m.tempos[i].Position)
+}
+
+// Seconds returns time in seconds of position in quarter notes.
+func (m *TempoMap) Seconds(ppq float64) float64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.seconds(ppq)
+}
+
+// PPQ returns position in quarter notes of time in seconds.
+func (m *TempoMap) PPQ(seconds float64) float64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.ppq(seconds)
+}
+
+// BarStart returns position of the bar start in quarter notes and time
+// signature of the bar that contains provided position.
+func (m *TempoMap) BarStart(ppq float64) (float64, TimeSignature) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.barStart(ppq)
+}
+
+// TimeInfo returns time info of provided position in samples. Tempo,
+// musical position, bar start, time signature and MIDI clock are valid.
+func (m *TempoMap) TimeInfo(samplePos float64, sampleRate signal.Frequency) TimeInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	ppq := m.ppq(samplePos / float64(sampleRate))
+	i := m.segment(ppq)
+	tempo, slope, _ := m.segmentTempo(i)
+	barStart, sig := m.barStart(ppq)
+	// distance to the next MIDI clock.
+	nextClock := math.Ceil(ppq*clocksPerQuarter) / clocksPerQuarter
+	toNextClock := (m.seconds(nextClock) - m.seconds(ppq)) * float64(sampleRate)
+	return TimeInfo{
+		SamplePos:          samplePos,
+		SampleRate:         float64(sampleRate),
+		PpqPos:             ppq,
+		Tempo:              tempo + slope*(ppq-m.tempos[i].Position),
+		BarStartPos:        barStart,
+		TimeSigNumerator:   int32(sig.Numerator),
+		TimeSigDenominator: int32(sig.Denominator),
+		SamplesToNextClock: int32(math.Round(toNextClock)),
+		Flags:              PpqPosValid | TempoValid | BarsValid | TimeSigValid | ClockValid,
+	}
+}
+
+// segment returns index of tempo point that starts segment with provided
+// position.
+func (m *TempoMap) segment(ppq float64) int {
+	i := sort.Search(len(m.tempos), func(i int) bool {
+		return m.tempos[i].Position > ppq
+	})
+	if i == 0 {
+		return 0
+	}
+	return i - 1
+}
+
+// segmentTempo returns tempo at the start of segment, tempo change per
+// quarter note and length of segment in quarter notes. Last segment has
+// infinite length.
+func (m *TempoMap) segmentTempo(i int) (tempo, slope, length float64) {
+	tempo, length = m.tempos[i].Tempo, math.Inf(1)
+	if i+1 < len(m.tempos) {
+		next := m.tempos[i+1]
+		length = next.Position - m.tempos[i].Position
+		if next.Ramp {
+			slope = (next.Tempo - tempo) / length
+		}
+	}
+	return tempo, slope, length
+}
+
+// segmentSeconds returns duration of first quarters of segment.
+func segmentSeconds(tempo, slope, quarters float64) float64 {
+	if slope == 0 {
+		return 60 * quarters / tempo
+	}
+	return 60 / slope * math.Log((tempo+slope*quarters)/tempo)
+}
+
+// segmentQuarters returns number of quarters played in first seconds of
+// segment.
+func segmentQuarters(tempo, slope, seconds float64) float64 {
+	if slope == 0 {
+		return seconds * tempo / 60
+	}
+	return tempo * (math.Exp(seconds*slope/60) - 1) / slope
+}
+
+func (m *TempoMap) seconds(ppq float64) float64 {
+	var seconds float64
+	for i := range m.tempos {
+		tempo, slope, length := m.segmentTempo(i)
+		if quarters := ppq - m.tempos[i].Position; quarters < length {
+			return seconds + segmentSeconds(tempo, slope, quarters)
+		}
+		seconds += segmentSeconds(tempo, slope, length)
+	}
+	return seconds
+}
+
+func (m *TempoMap) ppq(seconds float64) float64 {
+	for i := range m.tempos {
+		tempo, slope, length := m.segmentTempo(i)
+		duration := segmentSeconds(tempo, slope, length)
+		if seconds < duration {
+			return m.tempos[i].Position + segmentQuarters(tempo, slope, seconds)
+		}
+		seconds -= duration
+	}
+	return 0
+}
+
+func (m *TempoMap) barStart(ppq float64) (float64, TimeSignature) {
+	var start float64
+	sig := m.signatures[0]
+	for _, next := range m.signatures[1:] {
+		nextStart := start + float64(next.Bar-sig.Bar)*sig.quartersPerBar()
+		if nextStart > ppq {
+			break
+		}
+		start, sig = nextStart, next
+	}
+	bar := sig.quartersPerBar()
+	return start + math.Floor((ppq-start)/bar)*bar, sig
+}
+
+// quartersPerBar returns length of bar in quarter notes.
+func (s TimeSignature) quartersPerBar() float64 {
+	return float64(s.Numerator) * 4 / float64(s.Denominator)
+}
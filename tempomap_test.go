@@ -0,0 +1,69 @@
+package vst2
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTempoMap(t *testing.T) {
+	const sampleRate = 48000
+	m := NewTempoMap(120, 4, 4)
+	// 120 to 60 BPM ramp over 4 quarters, then 90 BPM.
+	m.SetTempo(4, 90)
+	m.SetTempo(12, 90)
+	m.RampTempo(8, 60)
+	m.SetTempo(4, 120)
+	m.SetTimeSignature(2, 3, 4)
+	m.SetTimeSignature(3, 6, 8)
+
+	assertEqual(t, "points", len(m.TempoPoints()), 4)
+	assertEqual(t, "tempo", m.TempoAt(2), 120.0)
+	assertEqual(t, "ramp tempo", m.TempoAt(6), 90.0)
+	assertEqual(t, "after ramp", m.TempoAt(10), 60.0)
+	assertEqual(t, "after change", m.TempoAt(12), 90.0)
+
+	assertEqual(t, "seconds", m.Seconds(4), 2.0)
+	rampSeconds := 60 / -15.0 * math.Log(60.0/120)
+	assertNear(t, "ramp seconds", m.Seconds(8), 2+rampSeconds)
+	assertNear(t, "ramp ppq", m.PPQ(m.Seconds(6)), 6)
+	assertNear(t, "constant ppq", m.PPQ(2+rampSeconds+1), 9)
+
+	start, sig := m.BarStart(9.5)
+	assertEqual(t, "3/4 bar", start, 8.0)
+	assertEqual(t, "3/4 signature", sig.Numerator, 3)
+	start, sig = m.BarStart(13)
+	assertEqual(t, "6/8 bar start", start, 11.0)
+	assertEqual(t, "6/8 signature", sig.Denominator, 8)
+
+	info := m.TimeInfo(sampleRate*1.75, sampleRate)
+	assertEqual(t, "info ppq", info.PpqPos, 3.5)
+	assertEqual(t, "info bar", info.BarStartPos, 0.0)
+	assertEqual(t, "info clock", info.SamplesToNextClock, int32(0))
+	assertEqual(t, "info signature", info.TimeSigNumerator, int32(4))
+}
+
+func TestTransportTempoMap(t *testing.T) {
+	m := NewTempoMap(120, 4, 4)
+	m.RampTempo(4, 60)
+	tr := NewTransport(100, 4, 4)
+	tr.SetSampleRate(48000)
+	assertEqual(t, "constant", tr.TempoAt(48000), 100.0)
+	tr.SetTempoMap(m)
+	assertEqual(t, "start", tr.TempoAt(0), 120.0)
+
+	callback := Host{GetTempoAt: tr.TempoAt}.Callback()
+	assertEqual(t, "callback", callback(hostTempoAt, 0, 0, nil, 0), int64(1200000))
+	tr.Play()
+	tr.Advance(48000)
+	info := tr.TimeInfo(0)
+	assertNear(t, "ppq", info.PpqPos, m.PPQ(1))
+	assertNear(t, "tempo", info.Tempo, m.TempoAt(m.PPQ(1)))
+	assertEqual(t, "slower", info.Tempo < 120, true)
+}
+
+func assertNear(t *testing.T, name string, result, expected float64) {
+	t.Helper()
+	if math.Abs(result-expected) > 1e-6 {
+		t.Fatalf("%v\nresult: \t%v\nexpected: \t%v", name, result, expected)
+	}
+}
@@ -0,0 +1,251 @@
+package vst2
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"pipelined.dev/signal"
+)
+
+// clocksPerQuarter is MIDI clock resolution.
+const clocksPerQuarter = 24
+
+// Transport simulates host sequencer. It keeps position of the block
+// being processed and provides TimeInfo for plugins. Position is
+// advanced only when transport is playing. Transport is safe for
+// concurrent use, but it should be advanced by a single processor.
+type Transport struct {
+	mu         sync.Mutex
+	sampleRate signal.Frequency
+	// constant is used if tempo map is not set.
+	constant             *TempoMap
+	tempoMap             *TempoMap
+	playing              bool
+	recording            bool
+	cycleActive          bool
+	cycleStart, cycleEnd float64
+	samplePos            float64
+	// changed is set when play, record or cycle state is changed and
+	// reset when transport is advanced after change was reported.
+	changed  bool
+	reported bool
+}
+
+// NewTransport returns stopped transport with provided tempo in BPM and
+// time signature.
+func NewTransport(tempo float64, numerator, denominator int) *Transport {
+	return &Transport{
+		sampleRate: 44100,
+		constant:   NewTempoMap(tempo, numerator, denominator),
+	}
+}
+
+// SetSampleRate sets sample rate used to convert samples to musical
+// position.
+func (t *Transport) SetSampleRate(sampleRate signal.Frequency) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sampleRate = sampleRate
+}
+
+// SetTempo sets constant tempo in BPM. It's ignored if tempo map is set.
+func (t *Transport) SetTempo(tempo float64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	sig := t.constant.signatures[0]
+	t.constant = NewTempoMap(tempo, sig.Numerator, sig.Denominator)
+}
+
+// SetTimeSignature sets constant time signature, e.g. 3, 4 for 3/4. It's
+// ignored if tempo map is set.
+func (t *Transport) SetTimeSignature(numerator, denominator int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.constant = NewTempoMap(t.constant.tempos[0].Tempo, numerator, denominator)
+}
+
+// SetTempoMap sets tempo map that defines tempo and time signature
+// changes. Nil map resets transport to constant tempo.
+func (t *Transport) SetTempoMap(m *TempoMap) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tempoMap = m
+}
+
+// TempoAt returns tempo in BPM at provided position in samples.
+func (t *Transport) TempoAt(samplePos int) float64 {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	m := t.tempos()
+	return m.TempoAt(m.PPQ(float64(samplePos) / float64(t.sampleRate)))
+}
+
+// Play starts the transport.
+func (t *Transport) Play() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.changed = t.changed || !t.playing
+	t.playing = true
+}
+
+// Stop stops the transport. Position is kept.
+func (t *Transport) Stop() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.changed = t.changed || t.playing || t.recording
+	t.playing, t.recording = false, false
+}
+
+// Record enables or disables record mode.
+func (t *Transport) Record(recording bool) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.changed = t.changed || t.recording != recording
+	t.recording = recording
+}
+
+// SetCycle sets cycle locators in quarter notes and enables cycle mode.
+// Position jumps to the start locator when it reaches the end locator.
+// Cycle is disabled if end locator doesn't follow start locator.
+func (t *Transport) SetCycle(start, end float64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	active := end > start
+	t.changed = t.changed || t.cycleActive != active
+	t.cycleActive = active
+	t.cycleStart, t.cycleEnd = start, end
+}
+
+// Playing returns true if transport is playing.
+func (t *Transport) Playing() bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.playing
+}
+
+// SetPosition moves transport to provided position in samples.
+func (t *Transport) SetPosition(samplePos int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.samplePos = float64(samplePos)
+}
+
+// Position returns current position in samples.
+func (t *Transport) Position() int {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return int(t.samplePos)
+}
+
+// Advance moves position by provided number of frames if transport is
+// playing. It must be called after every processed block.
+func (t *Transport) Advance(frames int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.reported {
+		t.changed, t.reported = false, false
+	}
+	if !t.playing {
+		return
+	}
+	t.samplePos += float64(frames)
+	if !t.cycleActive {
+		return
+	}
+	m, sampleRate := t.tempos(), float64(t.sampleRate)
+	if ppq := m.PPQ(t.samplePos / sampleRate); ppq >= t.cycleEnd {
+		ppq = t.cycleStart + math.Mod(ppq-t.cycleStart, t.cycleEnd-t.cycleStart)
+		t.samplePos = math.Round(m.Seconds(ppq) * sampleRate)
+	}
+}
+
+// TimeInfo returns time info at the start of the current block. Every
+// caller gets its own copy, so consumers that pass it to plugins must
+// keep it in their own memory. All fields are provided, flags only
+// affect system time that is set if NanosValid is requested. Nil
+// transport returns empty time info.
+func (t *Transport) TimeInfo(flags TimeInfoFlag) TimeInfo {
+	if t == nil {
+		return TimeInfo{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	info := t.tempos().TimeInfo(t.samplePos, t.sampleRate)
+	info.Flags |= t.transportFlags()
+	if t.cycleActive {
+		info.CycleStartPos, info.CycleEndPos = t.cycleStart, t.cycleEnd
+		info.Flags |= CyclePosValid
+	}
+	if flags&NanosValid == NanosValid {
+		info.NanoSeconds = float64(time.Now().UnixNano())
+		info.Flags |= NanosValid
+	}
+	return info
+}
+
+// transportFlags returns flags of transport state.
+func (t *Transport) transportFlags() TimeInfoFlag {
+	var flags TimeInfoFlag
+	if t.changed {
+		flags |= TransportChanged
+		t.reported = true
+	}
+	if t.playing {
+		flags |= TransportPlaying
+	}
+	if t.recording {
+		flags |= TransportRecording
+	}
+	if t.cycleActive {
+		flags |= TransportCycleActive
+	}
+	return flags
+}
+
+// tempos returns tempo map of the transport.
+func (t *Transport) tempos() *TempoMap {
+	if t.tempoMap != nil {
+		return t.tempoMap
+	}
+	return t.constant
+}
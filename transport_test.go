@@ -0,0 +1,61 @@
+package vst2_test
+
+import (
+	"testing"
+
+	"pipelined.dev/audio/vst2"
+)
+
+func TestTransport(t *testing.T) {
+	const sampleRate = 48000
+	tr := vst2.NewTransport(120, 3, 4)
+	tr.SetSampleRate(sampleRate)
+
+	info := tr.TimeInfo(0)
+	assertEqual(t, "stopped", info.Flags&vst2.TransportPlaying, vst2.TimeInfoFlag(0))
+	tr.Advance(sampleRate)
+	assertEqual(t, "stopped position", tr.Position(), 0)
+
+	tr.Play()
+	info = tr.TimeInfo(0)
+	assertEqual(t, "changed", info.Flags&vst2.TransportChanged, vst2.TransportChanged)
+	assertEqual(t, "playing", info.Flags&vst2.TransportPlaying, vst2.TransportPlaying)
+	// 2 seconds at 120 BPM is 4 quarters, bar in 3/4 is 3 quarters.
+	tr.Advance(2 * sampleRate)
+	info = tr.TimeInfo(0)
+	assertEqual(t, "change reported", info.Flags&vst2.TransportChanged, vst2.TimeInfoFlag(0))
+	assertEqual(t, "sample pos", info.SamplePos, float64(2*sampleRate))
+	assertEqual(t, "ppq pos", info.PpqPos, 4.0)
+	assertEqual(t, "bar start", info.BarStartPos, 3.0)
+	assertEqual(t, "on clock", info.SamplesToNextClock, int32(0))
+	valid := vst2.PpqPosValid | vst2.TempoValid | vst2.BarsValid | vst2.TimeSigValid | vst2.ClockValid
+	assertEqual(t, "valid", info.Flags&valid, valid)
+
+	// quarter is 24000 samples, clock is 1000 samples.
+	tr.Advance(250)
+	info = tr.TimeInfo(0)
+	assertEqual(t, "next clock", info.SamplesToNextClock, int32(750))
+
+	tr.SetPosition(0)
+	tr.SetCycle(1, 2)
+	tr.Record(true)
+	tr.Advance(60000)
+	info = tr.TimeInfo(vst2.NanosValid)
+	assertEqual(t, "cycle pos", info.PpqPos, 1.5)
+	assertEqual(t, "cycle", info.Flags&vst2.CyclePosValid, vst2.CyclePosValid)
+	assertEqual(t, "recording", info.Flags&vst2.TransportRecording, vst2.TransportRecording)
+	assertEqual(t, "nanos", info.Flags&vst2.NanosValid, vst2.NanosValid)
+
+	var empty *vst2.Transport
+	empty.SetTempo(120)
+	empty.SetTimeSignature(3, 4)
+	empty.SetTempoMap(nil)
+	empty.Play()
+	empty.Record(true)
+	empty.SetCycle(1, 2)
+	empty.SetPosition(100)
+	empty.Stop()
+	assertEqual(t, "nil transport", empty.TimeInfo(0), vst2.TimeInfo{})
+	assertEqual(t, "nil playing", empty.Playing(), false)
+	assertEqual(t, "nil position", empty.Position(), 0)
+}
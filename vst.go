@@ -2,7 +2,9 @@ package vst2
 
 import (
 	"fmt"
+	"math"
 	"strings"
+	"sync/atomic"
 )
 
 // EffectMagic is constant in every plugin.
@@ -416,6 +418,58 @@ const (
 	PinUseSpeaker
 )
 
+// Pin describes a single input or output of Go plugin.
+type Pin struct {
+	Label      string
+	ShortLabel string // Short name, recommended 6 chars + delimiter.
+	IsStereo   bool   // Pin is first of a stereo pair.
+	// UseSpeaker is set if Arrangement is valid and pin can be used for
+	// arrangement setup.
+	UseSpeaker  bool
+	Arrangement SpeakerArrangementType
+	// Sidechain is set if input pin belongs to sidechain bus. Sidechain
+	// pins must follow main input pins.
+	Sidechain bool
+}
+
+// properties returns pin properties that are reported to host.
+func (p Pin) properties() PinProperties {
+	props := PinProperties{
+		Flags:                  PinIsActive,
+		SpeakerArrangementType: p.Arrangement,
+	}
+	copyASCII(props.Label[:], p.Label)
+	copyASCII(props.ShortLabel[:], p.ShortLabel)
+	if p.IsStereo {
+		props.Flags |= PinIsStereo
+	}
+	if p.UseSpeaker {
+		props.Flags |= PinUseSpeaker
+	}
+	return props
+}
+
+// defaultSpeakerArrangement returns speaker arrangement type for the
+// provided number of channels.
+func defaultSpeakerArrangement(channels int) SpeakerArrangementType {
+	switch channels {
+	case 0:
+		return SpeakerArrEmpty
+	case 1:
+		return SpeakerArrMono
+	case 2:
+		return SpeakerArrStereo
+	case 4:
+		return SpeakerArr40Music
+	case 6:
+		return SpeakerArr51
+	case 8:
+		return SpeakerArr71Music
+	default:
+		return SpeakerArrUserDefined
+	}
+}
+
 // PluginCategory denotes the category of plugin.
 type PluginCategory int64
 
@@ -761,30 +815,80 @@ type (
 	Parameter struct {
 		Name              string
 		Unit              string
-		Value             float32
+		Default           float32 // Normalized value that is set when plugin is instantiated.
 		NotAutomated      bool
 		GetValueLabelFunc func(value float32) string
 		GetValueFunc      func(value float32) float32
+		ValueChangedFunc  func(value float32) // Called after normalized value is changed, e.g. by host.
+
+		// value holds float32 bits and is accessed atomically, because
+		// it's set by host in UI thread and read in processing thread.
+		value uint32
+
+		// Optional properties, reported to host with
+		// ParameterProperties.
+		ShortLabel string // Short name, recommended 6 chars + delimiter.
+		IsSwitch   bool   // Parameter is on/off switch.
+		CanRamp    bool   // Parameter can ramp up/down.
+		// Integer range of parameter, used if min and max are not equal.
+		MinInteger int
+		MaxInteger int
+		// Float steps, used if any of them is set.
+		StepFloat      float32
+		SmallStepFloat float32
+		LargeStepFloat float32
+		// Integer steps, used if any of them is set.
+		StepInteger      int
+		LargeStepInteger int
+		// Position where parameter should be displayed, starts with 1.
+		// Zero means default position.
+		DisplayIndex int
+		// Category of parameter. Parameters share category if they
+		// refer the same value.
+		Category *ParameterCategory
+	}
+
+	// ParameterCategory groups parameters when they are displayed by
+	// host.
+	ParameterCategory struct {
+		Label string
 	}
 
-	// Preset refers to plugin presets.
-	Preset struct {
-		name string
+	// Program is a named set of parameter values. Values are stored in
+	// the same order as plugin parameters.
+	Program struct {
+		Name   string
+		Values []float32
 	}
 )
 
+// Value returns normalized value of the parameter. It's safe to call it
+// concurrently with SetValue.
+func (e *Parameter) Value() float32 {
+	return math.Float32frombits(atomic.LoadUint32(&e.value))
+}
+
+// SetValue sets normalized value of the parameter and calls
+// ValueChangedFunc, when it was set for the Parameter.
+func (e *Parameter) SetValue(value float32) {
+	atomic.StoreUint32(&e.value, math.Float32bits(value))
+	if e.ValueChangedFunc != nil {
+		e.ValueChangedFunc(value)
+	}
+}
+
 // GetValue should be called in ProcessDoubleFunc or ProcessFloatFunc and will be called in GetDisplayVal.
 // It returns the plain Value or the return value of GetValueFunc, when it was set for the Parameter
-func (e Parameter) GetValue() float32 {
+func (e *Parameter) GetValue() float32 {
 	if e.GetValueFunc == nil {
-		return e.Value
+		return e.Value()
 	}
-	return e.GetValueFunc(e.Value)
+	return e.GetValueFunc(e.Value())
 }
 
 // GetValueLabel will be called in HostOpcode plugGetParamDisplay. Return a string formatted float value or the return
 // value of GetValueLabelFunc, when it was set for the Parameter
-func (e Parameter) GetValueLabel() string {
+func (e *Parameter) GetValueLabel() string {
 	if e.GetValueLabelFunc == nil {
 		return fmt.Sprintf("%f", e.GetValue())
 	}